@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// curlSkipHeaders are left out of curl commands, as curl sets them itself
+var curlSkipHeaders = map[string]bool{
+	"content-length":  true,
+	"accept-encoding": true,
+	"connection":      true,
+	"host":            true,
+}
+
+// APICallRecorder records the XHR and fetch requests pages make as
+// replayable requests. Repeats of the same method, URL and body are kept
+// once. It is safe for concurrent use.
+type APICallRecorder struct {
+	mu       sync.Mutex
+	requests map[network.RequestID]*Request
+	pending  map[network.RequestID]map[string]string
+	skipped  map[network.RequestID]bool
+	order    []*Request
+	seen     map[string]bool
+}
+
+// NewAPICallRecorder creates an empty API call recorder
+func NewAPICallRecorder() *APICallRecorder {
+	return &APICallRecorder{
+		requests: make(map[network.RequestID]*Request),
+		pending:  make(map[network.RequestID]map[string]string),
+		skipped:  make(map[network.RequestID]bool),
+		seen:     make(map[string]bool),
+	}
+}
+
+// add records an API call made by a page
+func (ar *APICallRecorder) add(requestID network.RequestID, req *Request) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	key := req.Method + " " + req.URL + "\n" + req.Body
+	if ar.seen[key] {
+		ar.skipped[requestID] = true
+		delete(ar.pending, requestID)
+		return
+	}
+	ar.seen[key] = true
+	if headers, ok := ar.pending[requestID]; ok {
+		mergeHeaders(req, headers)
+		delete(ar.pending, requestID)
+	}
+	req.Raw = rawHTTPRequest(req)
+	ar.requests[requestID] = req
+	ar.order = append(ar.order, req)
+}
+
+// setHeaders merges the headers actually sent, which include cookies, into
+// the provisional headers of an API call. Headers reported before the
+// request itself are kept until it is added or skipped.
+func (ar *APICallRecorder) setHeaders(requestID network.RequestID, headers map[string]string) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	req, ok := ar.requests[requestID]
+	if !ok {
+		if ar.skipped[requestID] {
+			return
+		}
+		if ar.pending[requestID] == nil {
+			ar.pending[requestID] = make(map[string]string)
+		}
+		for name, value := range headers {
+			setHeader(ar.pending[requestID], name, value)
+		}
+		return
+	}
+	mergeHeaders(req, headers)
+	req.Raw = rawHTTPRequest(req)
+}
+
+// skip marks a request that is not an API call, so its sent headers are
+// not kept
+func (ar *APICallRecorder) skip(requestID network.RequestID) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	ar.skipped[requestID] = true
+	delete(ar.pending, requestID)
+}
+
+// done forgets a finished request, so later events for it are ignored
+func (ar *APICallRecorder) done(requestID network.RequestID) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	delete(ar.requests, requestID)
+	delete(ar.pending, requestID)
+	delete(ar.skipped, requestID)
+}
+
+// Reset forgets the requests of the previous page, including ones that
+// never finished, such as long polls and streams. Recorded calls are kept.
+func (ar *APICallRecorder) Reset() {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	ar.requests = make(map[network.RequestID]*Request)
+	ar.pending = make(map[network.RequestID]map[string]string)
+	ar.skipped = make(map[network.RequestID]bool)
+}
+
+// mergeHeaders sets headers on a request, replacing any provisional header
+// of the same name regardless of case
+func mergeHeaders(req *Request, headers map[string]string) {
+	if req.Headers == nil {
+		req.Headers = make(map[string]string)
+	}
+	for name, value := range headers {
+		setHeader(req.Headers, name, value)
+	}
+}
+
+// Requests returns the recorded API calls in the order they were made
+func (ar *APICallRecorder) Requests() []*Request {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+	return append([]*Request(nil), ar.order...)
+}
+
+// listenForAPICalls records the XHR and fetch requests pages make
+func (nc *NetworkCapture) listenForAPICalls(ctx context.Context) {
+	ar := nc.APICalls
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if !isAPICall(ev.Type) || ev.Request == nil {
+				ar.skip(ev.RequestID)
+				return
+			}
+			req := &Request{
+				Method:  ev.Request.Method,
+				URL:     ev.Request.URL,
+				Body:    postData(ev.Request),
+				Headers: flattenHeaders(ev.Request.Headers),
+				Tag:     strings.ToLower(string(ev.Type)),
+			}
+			if page := nc.Traffic.Page(); page != nil {
+				req.Source = page.URL
+				req.Depth = page.Depth
+			}
+			ar.add(ev.RequestID, req)
+
+		case *network.EventRequestWillBeSentExtraInfo:
+			// Current Chrome reports the headers actually sent, cookies
+			// included, only here. It can arrive before or after the request,
+			// whose type is not known until then.
+			ar.setHeaders(ev.RequestID, flattenHeaders(ev.Headers))
+
+		case *network.EventResponseReceived:
+			if isAPICall(ev.Type) && ev.Response != nil && len(ev.Response.RequestHeaders) > 0 {
+				ar.setHeaders(ev.RequestID, flattenHeaders(ev.Response.RequestHeaders))
+			}
+
+		case *network.EventLoadingFinished:
+			ar.done(ev.RequestID)
+
+		case *network.EventLoadingFailed:
+			ar.done(ev.RequestID)
+		}
+	})
+}
+
+// isAPICall reports whether a resource type is a script-initiated request
+func isAPICall(resourceType network.ResourceType) bool {
+	return resourceType == network.ResourceTypeXHR || resourceType == network.ResourceTypeFetch
+}
+
+// sortedHeaderNames returns the header names to replay, in a stable order.
+// HTTP/2 pseudo-headers such as :authority are left out.
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		if !strings.HasPrefix(name, ":") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rawHTTPRequest renders a request as HTTP/1.1 request text
+func rawHTTPRequest(req *Request) string {
+	parsedURL, err := url.Parse(req.URL)
+	if err != nil {
+		return ""
+	}
+
+	var raw strings.Builder
+	raw.WriteString(req.Method + " " + parsedURL.RequestURI() + " HTTP/1.1\r\n")
+	if lookupHeader(req.Headers, "Host") == "" {
+		raw.WriteString("Host: " + parsedURL.Host + "\r\n")
+	}
+	for _, name := range sortedHeaderNames(req.Headers) {
+		// Repeated headers are reported joined by newlines
+		for _, value := range strings.Split(req.Headers[name], "\n") {
+			raw.WriteString(name + ": " + value + "\r\n")
+		}
+	}
+	raw.WriteString("\r\n")
+	raw.WriteString(req.Body)
+	return raw.String()
+}
+
+// lookupHeader finds a header value case-insensitively
+func lookupHeader(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// shellQuote quotes a string for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// curlCommand renders a request as a curl command line
+func curlCommand(req *Request) string {
+	var cmd strings.Builder
+	cmd.WriteString("curl")
+	if req.Method != "GET" {
+		cmd.WriteString(" -X " + shellQuote(req.Method))
+	}
+	cmd.WriteString(" " + shellQuote(req.URL))
+	for _, name := range sortedHeaderNames(req.Headers) {
+		if curlSkipHeaders[strings.ToLower(name)] {
+			continue
+		}
+		for _, value := range strings.Split(req.Headers[name], "\n") {
+			cmd.WriteString(" \\\n  -H " + shellQuote(name+": "+value))
+		}
+	}
+	if req.Body != "" {
+		cmd.WriteString(" \\\n  --data-raw " + shellQuote(req.Body))
+	}
+	if strings.Contains(lookupHeader(req.Headers, "Accept-Encoding"), "gzip") {
+		cmd.WriteString(" \\\n  --compressed")
+	}
+	return cmd.String()
+}
+
+// SaveAPICalls writes the API calls pages made to api_calls.json, and as
+// curl commands to api_calls.sh
+func (nc *NetworkCapture) SaveAPICalls() {
+	if nc.APICalls == nil {
+		return
+	}
+	requests := nc.APICalls.Requests()
+	if len(requests) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(requests, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode API calls", "error", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(nc.OutputDir, "api_calls.json"), data, 0644); err != nil {
+		slog.Error("Failed to write API calls", "error", err)
+		return
+	}
+
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\n# API calls made by crawled pages\n")
+	for _, req := range requests {
+		script.WriteString("\n# " + req.Tag + " from " + req.Source + "\n")
+		script.WriteString(curlCommand(req) + "\n")
+	}
+	if err := os.WriteFile(filepath.Join(nc.OutputDir, "api_calls.sh"), []byte(script.String()), 0755); err != nil {
+		slog.Error("Failed to write API call commands", "error", err)
+		return
+	}
+	slog.Info("Saved API calls", "count", len(requests))
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRawHTTPRequest(t *testing.T) {
+	req := &Request{
+		Method: "POST",
+		URL:    "https://api.example.com/v1/items?page=2",
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+			":authority":   "api.example.com",
+			"Cookie":       "a=1",
+		},
+		Body: `{"name":"x"}`,
+	}
+	want := "POST /v1/items?page=2 HTTP/1.1\r\n" +
+		"Host: api.example.com\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Cookie: a=1\r\n" +
+		"\r\n" +
+		`{"name":"x"}`
+	if got := rawHTTPRequest(req); got != want {
+		t.Errorf("rawHTTPRequest() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestCurlCommand(t *testing.T) {
+	req := &Request{
+		Method: "PUT",
+		URL:    "https://example.com/api/notes/1",
+		Headers: map[string]string{
+			"Accept-Encoding": "gzip, deflate",
+			"Content-Length":  "17",
+			"X-Note":          "it's",
+		},
+		Body: `{"text":"hi"}`,
+	}
+	want := "curl -X 'PUT' 'https://example.com/api/notes/1' \\\n" +
+		"  -H 'X-Note: it'\\''s' \\\n" +
+		"  --data-raw '{\"text\":\"hi\"}' \\\n" +
+		"  --compressed"
+	if got := curlCommand(req); got != want {
+		t.Errorf("curlCommand() =\n%s\nwant\n%s", got, want)
+	}
+
+	get := &Request{Method: "GET", URL: "https://example.com/api/me"}
+	if got := curlCommand(get); got != "curl 'https://example.com/api/me'" {
+		t.Errorf("curlCommand() for GET = %q", got)
+	}
+}
+
+func TestAPICallRecorder(t *testing.T) {
+	ar := NewAPICallRecorder()
+	ar.add("1", &Request{Method: "GET", URL: "https://example.com/api/me", Headers: map[string]string{"Accept": "*/*"}})
+	ar.add("2", &Request{Method: "GET", URL: "https://example.com/api/me"})
+	ar.add("3", &Request{Method: "POST", URL: "https://example.com/api/me", Body: "x=1"})
+	ar.setHeaders("1", map[string]string{"Accept": "*/*", "Cookie": "session=abc"})
+
+	requests := ar.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("recorded %d API calls, want 2 after dropping the repeat", len(requests))
+	}
+	if got := requests[0].Headers["Cookie"]; got != "session=abc" {
+		t.Errorf("sent headers were not applied, Cookie = %q", got)
+	}
+	if want := "GET /api/me HTTP/1.1\r\nHost: example.com\r\nAccept: */*\r\nCookie: session=abc\r\n\r\n"; requests[0].Raw != want {
+		t.Errorf("Raw = %q, want %q", requests[0].Raw, want)
+	}
+}
+
+func TestAPICallRecorderExtraInfo(t *testing.T) {
+	ar := NewAPICallRecorder()
+
+	// Sent headers reported before the request are applied when it is added
+	ar.setHeaders("1", map[string]string{"accept": "application/json", "cookie": "session=abc"})
+	ar.add("1", &Request{Method: "GET", URL: "https://example.com/api/me", Headers: map[string]string{"Accept": "application/json"}})
+
+	// and after it, they are merged into the provisional headers
+	ar.add("2", &Request{Method: "GET", URL: "https://example.com/api/cart", Headers: map[string]string{"X-Requested-With": "fetch"}})
+	ar.setHeaders("2", map[string]string{"cookie": "session=abc"})
+
+	// Headers of finished or unrecorded requests are dropped
+	ar.setHeaders("3", map[string]string{"cookie": "session=abc"})
+	ar.done("3")
+	ar.add("3", &Request{Method: "GET", URL: "https://example.com/api/other"})
+
+	// Headers of other request types are not kept, whichever event comes first
+	ar.skip("4")
+	ar.setHeaders("4", map[string]string{"cookie": "session=abc"})
+	ar.setHeaders("5", map[string]string{"cookie": "session=abc"})
+	ar.skip("5")
+	if len(ar.pending) != 0 || len(ar.requests) != 3 {
+		t.Errorf("kept %d pending headers and %d open requests, want 0 and 3", len(ar.pending), len(ar.requests))
+	}
+
+	// Requests that never finish are forgotten when the page changes
+	ar.setHeaders("6", map[string]string{"cookie": "session=abc"})
+	ar.Reset()
+	if len(ar.pending) != 0 || len(ar.requests) != 0 || len(ar.skipped) != 0 {
+		t.Error("Reset() kept the previous page's requests")
+	}
+
+	requests := ar.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("recorded %d API calls, want 3", len(requests))
+	}
+	if want := map[string]string{"accept": "application/json", "cookie": "session=abc"}; !reflect.DeepEqual(requests[0].Headers, want) {
+		t.Errorf("headers sent before the request = %v, want %v", requests[0].Headers, want)
+	}
+	if want := map[string]string{"X-Requested-With": "fetch", "cookie": "session=abc"}; !reflect.DeepEqual(requests[1].Headers, want) {
+		t.Errorf("headers sent after the request = %v, want %v", requests[1].Headers, want)
+	}
+	if !strings.Contains(requests[1].Raw, "cookie: session=abc\r\n") {
+		t.Errorf("Raw was not updated with the sent headers: %q", requests[1].Raw)
+	}
+	if len(requests[2].Headers) != 0 {
+		t.Errorf("headers of a finished request were applied: %v", requests[2].Headers)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// blobDir is the directory under the output directory holding response bodies
+const blobDir = "blobs"
+
+// BlobIndexEntry maps a captured URL to the blob holding its body
+type BlobIndexEntry struct {
+	URL      string `json:"url"`
+	SHA256   string `json:"sha256"`
+	Blob     string `json:"blob"`
+	Size     int    `json:"size"`
+	MimeType string `json:"mime_type"`
+
+	DeclaredMimeType string `json:"declared_mime_type,omitempty"`
+	DetectedMimeType string `json:"detected_mime_type,omitempty"`
+}
+
+// blobHash returns the hex SHA-256 of a body
+func blobHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeBlob stores body under blobs/<first two hex digits>/<sha256> unless it
+// is already there, and returns the blob path relative to the output directory
+func writeBlob(outputDir, hash string, body []byte) (string, error) {
+	relPath := filepath.Join(blobDir, hash[:2], hash)
+	fullPath := filepath.Join(outputDir, relPath)
+
+	if _, err := os.Stat(fullPath); err == nil {
+		return filepath.ToSlash(relPath), nil
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so an interrupted crawl never leaves a
+	// truncated blob under its final content address
+	tmpPath := fullPath + ".tmp"
+	if err := os.WriteFile(tmpPath, body, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, fullPath); err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+// writeBlobIndex writes the URL to blob index to index.json
+func writeBlobIndex(outputDir string, index []BlobIndexEntry) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, "index.json"), data, 0644)
+}
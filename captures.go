@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// captureDir holds screenshots and PDFs when not writing a mirror
+const captureDir = "captures"
+
+// Default browser viewport
+const (
+	defaultViewportWidth  = 1280
+	defaultViewportHeight = 800
+)
+
+// PageCapture records the screenshot and PDF rendered for a crawled page
+type PageCapture struct {
+	URL        string
+	Depth      int
+	Source     string
+	Screenshot string
+	PDF        string
+}
+
+// parseViewport parses a WIDTHxHEIGHT viewport size such as 1280x800
+func parseViewport(viewport string) (int64, int64, error) {
+	parts := strings.SplitN(strings.ToLower(viewport), "x", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid viewport %q, expected WIDTHxHEIGHT", viewport)
+	}
+	width, err := strconv.ParseInt(strings.TrimSpace(parts[0]), 10, 64)
+	if err != nil || width <= 0 {
+		return 0, 0, fmt.Errorf("invalid viewport width in %q", viewport)
+	}
+	height, err := strconv.ParseInt(strings.TrimSpace(parts[1]), 10, 64)
+	if err != nil || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid viewport height in %q", viewport)
+	}
+	return width, height, nil
+}
+
+// pageCapturePath returns where a capture of a page is saved, relative to the
+// output directory. In mirror mode it sits next to the file the page is saved
+// as, including the hashed names given to colliding URLs.
+func (nc *NetworkCapture) pageCapturePath(pageURL, ext string) string {
+	base := strings.TrimSuffix(nc.mirrorLocalPath(pageURL, "text/html"), ".html") + ext
+	if nc.Mirror {
+		return base
+	}
+	return filepath.Join(captureDir, base)
+}
+
+// capturePage saves a full-page screenshot and/or a PDF of the current page
+func (nc *NetworkCapture) capturePage(ctx context.Context, job *Request) {
+	if !nc.Screenshots && !nc.PDF {
+		return
+	}
+	capture := PageCapture{URL: job.URL, Depth: job.Depth, Source: job.Source}
+
+	if nc.Screenshots {
+		var screenshot []byte
+		err := runWithTimeout(ctx, nc.PageTimeout, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			screenshot, err = page.CaptureScreenshot().
+				WithFormat(page.CaptureScreenshotFormatPng).
+				WithCaptureBeyondViewport(true).
+				WithFromSurface(true).
+				Do(ctx)
+			return err
+		}))
+		if err != nil {
+			slog.Warn("Failed to capture screenshot", "url", job.URL, "depth", job.Depth, "error", failureReason(err, nc.PageTimeout))
+			nc.recordFailure(FailureCapture, job, err, nc.PageTimeout, 1)
+		} else if path, err := nc.writeCapture(job.URL, ".png", screenshot); err == nil {
+			capture.Screenshot = path
+		}
+	}
+
+	if nc.PDF {
+		var pdf []byte
+		err := runWithTimeout(ctx, nc.PageTimeout, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			pdf, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+			return err
+		}))
+		if err != nil {
+			slog.Warn("Failed to render PDF", "url", job.URL, "depth", job.Depth, "error", failureReason(err, nc.PageTimeout))
+			nc.recordFailure(FailureCapture, job, err, nc.PageTimeout, 1)
+		} else if path, err := nc.writeCapture(job.URL, ".pdf", pdf); err == nil {
+			capture.PDF = path
+		}
+	}
+
+	if capture.Screenshot != "" || capture.PDF != "" {
+		nc.Captures = append(nc.Captures, capture)
+	}
+}
+
+// writeCapture writes a capture file and returns its path relative to the output directory
+func (nc *NetworkCapture) writeCapture(pageURL, ext string, data []byte) (string, error) {
+	relPath := nc.pageCapturePath(pageURL, ext)
+	fullPath := filepath.Join(nc.OutputDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		slog.Error("Failed to create capture directory", "url", pageURL, "error", err)
+		return "", err
+	}
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		slog.Error("Failed to write capture", "url", pageURL, "error", err)
+		return "", err
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+// galleryTemplate renders the capture gallery index
+var galleryTemplate = template.Must(template.New("gallery").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Crawl gallery</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(320px, 1fr)); gap: 1.5em; }
+.card { border: 1px solid #ddd; padding: 0.75em; word-break: break-all; }
+.card img { width: 100%; max-height: 400px; object-fit: cover; object-position: top; border: 1px solid #eee; }
+.meta { color: #666; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Crawl gallery</h1>
+<p>{{len .}} pages captured</p>
+<div class="grid">
+{{range .}}<div class="card">
+{{if .Screenshot}}<a href="{{.Screenshot}}"><img src="{{.Screenshot}}" alt="{{.URL}}" loading="lazy"></a>{{end}}
+<p><a href="{{.URL}}">{{.URL}}</a></p>
+<p class="meta">Depth {{.Depth}}{{if .Source}} &middot; from <a href="{{.Source}}">{{.Source}}</a>{{end}}</p>
+{{if .PDF}}<p class="meta"><a href="{{.PDF}}">PDF</a></p>{{end}}
+</div>
+{{end}}</div>
+</body>
+</html>
+`))
+
+// SaveGallery writes gallery.html linking every capture to its page
+func (nc *NetworkCapture) SaveGallery() {
+	if len(nc.Captures) == 0 {
+		return
+	}
+
+	file, err := os.Create(filepath.Join(nc.OutputDir, "gallery.html"))
+	if err != nil {
+		slog.Error("Failed to create gallery", "error", err)
+		return
+	}
+	defer file.Close()
+
+	if err := galleryTemplate.Execute(file, nc.Captures); err != nil {
+		slog.Error("Failed to write gallery", "error", err)
+		return
+	}
+	slog.Info("Saved gallery", "pages", len(nc.Captures))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// cssCommentPattern matches comments in stylesheets
+var cssCommentPattern = regexp.MustCompile(`(?s)/\*.*?\*/`)
+
+// extractCSSLinks finds the url(...) and @import references in a stylesheet,
+// an inline <style> block or a style attribute, resolved against baseURL
+func extractCSSLinks(css, baseURL string, resolver *URLResolver) []LinkInfo {
+	var links []LinkInfo
+	css = cssCommentPattern.ReplaceAllString(css, "")
+	for _, groups := range cssURLPattern.FindAllStringSubmatch(css, -1) {
+		ref, attribute := groups[2], "url"
+		if ref == "" {
+			ref, attribute = groups[5], "import"
+		}
+		ref = strings.TrimSpace(ref)
+		// Inline data and references within the document are not resources
+		if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(strings.ToLower(ref), "data:") {
+			continue
+		}
+
+		for _, resolved := range resolver.Resolve(ref, baseURL) {
+			links = append(links, LinkInfo{
+				URL:        resolved.URL,
+				Tag:        "css",
+				Attribute:  attribute,
+				Resolution: resolved.Resolution,
+			})
+		}
+	}
+	return links
+}
+
+// isStylesheet reports whether a MIME type is CSS
+func isStylesheet(mimeType string) bool {
+	return baseMimeType(mimeType) == "text/css"
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractCSSLinks(t *testing.T) {
+	css := `@import "base.css";
+	@import url('print.css') print;
+	/* url(commented-out.png) */
+	.logo { background: url(../img/logo.png) no-repeat; }
+	@font-face { src: url("/fonts/site.woff2") format("woff2"); }
+	.icon { background: url(data:image/png;base64,iVBORw0KGgo=); }
+	.mask { mask: url(#clip); }
+	.cdn { background: url(https://cdn.example.net/bg.jpg); }`
+
+	got := extractCSSLinks(css, "https://example.com/css/site.css", nil)
+	want := []LinkInfo{
+		{URL: "https://example.com/css/base.css", Tag: "css", Attribute: "import", Resolution: ResolutionStandard},
+		{URL: "https://example.com/css/print.css", Tag: "css", Attribute: "url", Resolution: ResolutionStandard},
+		{URL: "https://example.com/img/logo.png", Tag: "css", Attribute: "url", Resolution: ResolutionStandard},
+		{URL: "https://example.com/fonts/site.woff2", Tag: "css", Attribute: "url", Resolution: ResolutionStandard},
+		{URL: "https://cdn.example.net/bg.jpg", Tag: "css", Attribute: "url", Resolution: ResolutionLiteral},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractCSSLinks() =\n%v\nwant\n%v", got, want)
+	}
+}
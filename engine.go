@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http/cookiejar"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Crawl engines
+const (
+	EngineChrome = "chrome"
+	EngineHTTP   = "http"
+)
+
+// pageSettleDelay is how long a rendered page is given to run its scripts
+const pageSettleDelay = 1 * time.Second
+
+// PageResponse is the response of a loaded page
+type PageResponse struct {
+	Status      int
+	ContentType string
+}
+
+// Engine loads pages and fetches resources. The frontier, scope, extraction
+// and output are shared by every engine, so switching engines is a flag.
+type Engine interface {
+	// Start prepares the engine and returns the context the crawl runs in
+	Start(ctx context.Context) (context.Context, error)
+	// Load loads a page, bounded by the page timeout
+	Load(ctx context.Context, pageURL string) (*PageResponse, error)
+	// HTML returns the HTML of the page loaded last
+	HTML(ctx context.Context) (string, error)
+	// Frames returns the documents of the page loaded last for extraction,
+	// the page itself first. Call it after HTML, which lets the page settle.
+	Frames(ctx context.Context) ([]PageFrame, error)
+	// FetchResource fetches a resource and returns its body and declared Content-Type
+	FetchResource(ctx context.Context, resourceURL string) ([]byte, string, error)
+	// CapturePage saves a screenshot and/or PDF of the page loaded last, where supported
+	CapturePage(ctx context.Context, job *Request)
+	// Close shuts the engine down
+	Close()
+}
+
+// NewEngine creates the named engine for a capture
+func NewEngine(name string, nc *NetworkCapture, proxy string, viewportWidth, viewportHeight int64) (Engine, error) {
+	switch name {
+	case EngineChrome:
+		return &ChromeEngine{
+			capture:        nc,
+			proxy:          proxy,
+			viewportWidth:  viewportWidth,
+			viewportHeight: viewportHeight,
+		}, nil
+	case EngineHTTP:
+		if nc.Screenshots || nc.PDF {
+			return nil, fmt.Errorf("screenshots and PDFs need the %s engine", EngineChrome)
+		}
+		return &HTTPEngine{capture: nc}, nil
+	}
+	return nil, fmt.Errorf("unknown engine %q (expected chrome or http)", name)
+}
+
+// ChromeEngine renders pages in headless Chrome, so script-generated links
+// and requests are captured
+type ChromeEngine struct {
+	capture        *NetworkCapture
+	proxy          string
+	viewportWidth  int64
+	viewportHeight int64
+	cancels        []context.CancelFunc
+}
+
+// Start launches the browser, sharing the proxy with HTTP fetches
+func (e *ChromeEngine) Start(ctx context.Context) (context.Context, error) {
+	allocatorOptions := chromedp.DefaultExecAllocatorOptions[:]
+	if e.proxy != "" {
+		allocatorOptions = append(allocatorOptions, chromedp.ProxyServer(e.proxy))
+	}
+	ctx, cancelAllocator := chromedp.NewExecAllocator(ctx, allocatorOptions...)
+	ctx, cancelBrowser := chromedp.NewContext(
+		ctx,
+		chromedp.WithLogf(chromedpLogf(slog.LevelDebug)),
+		chromedp.WithErrorf(chromedpLogf(slog.LevelWarn)),
+	)
+	e.cancels = append(e.cancels, cancelBrowser, cancelAllocator)
+
+	// Enable network events
+	if err := chromedp.Run(ctx, network.Enable()); err != nil {
+		return nil, fmt.Errorf("failed to enable network: %w", err)
+	}
+	e.capture.listenForTraffic(ctx)
+	e.capture.listenForStreams(ctx)
+	e.capture.listenForAPICalls(ctx)
+
+	// Set the viewport pages are rendered in
+	if err := chromedp.Run(ctx, chromedp.EmulateViewport(e.viewportWidth, e.viewportHeight)); err != nil {
+		slog.Warn("Failed to set viewport", "error", err)
+	}
+
+	// Custom headers and interception rules are applied per request
+	if e.capture.Interceptor.Active() {
+		if err := e.capture.interceptRequests(ctx); err != nil {
+			return ctx, fmt.Errorf("failed to enable request interception: %w", err)
+		}
+	}
+	return ctx, nil
+}
+
+// Load navigates the browser to a page
+func (e *ChromeEngine) Load(ctx context.Context, pageURL string) (*PageResponse, error) {
+	// A blocked navigation would only fail with net::ERR_BLOCKED_BY_CLIENT
+	if e.capture.Interceptor.Blocked(pageURL, string(network.ResourceTypeDocument)) {
+		return nil, errBlocked
+	}
+	resp, err := runResponseWithTimeout(ctx, e.capture.PageTimeout, chromedp.Navigate(pageURL))
+	if err != nil {
+		return nil, err
+	}
+	page := &PageResponse{ContentType: contentTypeOf(resp)}
+	if resp != nil {
+		page.Status = int(resp.Status)
+	}
+	return page, nil
+}
+
+// HTML returns the rendered page after giving its scripts time to settle
+func (e *ChromeEngine) HTML(ctx context.Context) (string, error) {
+	time.Sleep(pageSettleDelay)
+	var pageHTML string
+	err := runWithTimeout(ctx, e.capture.ScriptTimeout, chromedp.OuterHTML("html", &pageHTML))
+	return pageHTML, err
+}
+
+// Frames returns the rendered page with open shadow roots flattened,
+// followed by its in-scope iframes
+func (e *ChromeEngine) Frames(ctx context.Context) ([]PageFrame, error) {
+	var document *cdp.Node
+	err := runWithTimeout(ctx, e.capture.ScriptTimeout, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		document, err = dom.GetDocument().WithDepth(-1).WithPierce(true).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return flattenDocument(document, func(frameURL string) bool {
+		return isSameDomain(e.capture.TargetHost, frameURL)
+	})
+}
+
+// FetchResource fetches a resource in the browser or over HTTP, by fetch mode
+func (e *ChromeEngine) FetchResource(ctx context.Context, resourceURL string) ([]byte, string, error) {
+	return e.capture.fetchResource(ctx, resourceURL)
+}
+
+// CapturePage takes the screenshot and PDF of the current page
+func (e *ChromeEngine) CapturePage(ctx context.Context, job *Request) {
+	e.capture.capturePage(ctx, job)
+}
+
+// Close shuts the browser down
+func (e *ChromeEngine) Close() {
+	for _, cancel := range e.cancels {
+		cancel()
+	}
+}
+
+// HTTPEngine fetches pages and resources with a plain HTTP client, without
+// running scripts. It needs no browser and suits server-rendered sites.
+type HTTPEngine struct {
+	capture *NetworkCapture
+	page    *Exchange
+}
+
+// Start gives the HTTP client a cookie jar, so session cookies set by the
+// site are sent back like a browser would
+func (e *HTTPEngine) Start(ctx context.Context) (context.Context, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	e.capture.Fetcher.Client.Jar = jar
+	return ctx, nil
+}
+
+// Load fetches a page
+func (e *HTTPEngine) Load(ctx context.Context, pageURL string) (*PageResponse, error) {
+	// A page that fails to load, or is blocked, leaves nothing to extract
+	e.page = nil
+	exchange, err := e.capture.fetchHTTP(ctx, pageURL, e.capture.PageTimeout)
+	if err != nil {
+		return nil, err
+	}
+	exchange.ResourceType = string(network.ResourceTypeDocument)
+	e.capture.recordExchange(exchange)
+	e.page = exchange
+	return &PageResponse{Status: exchange.Status, ContentType: exchange.ResponseHeaders["Content-Type"]}, nil
+}
+
+// HTML returns the body of the page as served
+func (e *HTTPEngine) HTML(ctx context.Context) (string, error) {
+	if e.page == nil {
+		return "", nil
+	}
+	return string(e.page.Body), nil
+}
+
+// Frames returns the body of the page as served. Iframes are not loaded.
+func (e *HTTPEngine) Frames(ctx context.Context) ([]PageFrame, error) {
+	if e.page == nil {
+		return []PageFrame{{}}, nil
+	}
+	return []PageFrame{{URL: e.page.URL, HTML: string(e.page.Body)}}, nil
+}
+
+// FetchResource fetches a resource over HTTP
+func (e *HTTPEngine) FetchResource(ctx context.Context, resourceURL string) ([]byte, string, error) {
+	exchange, err := e.capture.fetchHTTP(ctx, resourceURL, e.capture.ResourceTimeout)
+	if errors.Is(err, errBlocked) {
+		return nil, "", err
+	}
+	if err != nil {
+		slog.Warn("Failed to fetch resource", "url", resourceURL, "error", failureReason(err, e.capture.ResourceTimeout))
+		return nil, "", err
+	}
+	e.capture.recordExchange(exchange)
+	return exchange.Body, exchange.ResponseHeaders["Content-Type"], nil
+}
+
+// CapturePage does nothing, as nothing is rendered
+func (e *HTTPEngine) CapturePage(ctx context.Context, job *Request) {}
+
+// Close does nothing
+func (e *HTTPEngine) Close() {}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// maxRedirects is the number of redirects followed outside the browser, as
+// in net/http
+const maxRedirects = 10
+
+// Resource fetch modes
+const (
+	FetchBrowser = "browser"
+	FetchHTTP    = "http"
+	FetchHybrid  = "hybrid"
+)
+
+// FetchRule picks a fetch mode for resources whose URL matches a pattern,
+// or whose MIME type, guessed from the URL, matches a type pattern
+type FetchRule struct {
+	URLPattern  *regexp.Regexp
+	MimePattern string
+	Mode        string
+}
+
+// parseFetchRule parses PATTERN=MODE, where PATTERN is a MIME type such as
+// image/* or text/css, or a URL regular expression prefixed with url:
+func parseFetchRule(rule string) (FetchRule, error) {
+	eq := strings.LastIndex(rule, "=")
+	if eq <= 0 {
+		return FetchRule{}, fmt.Errorf("invalid fetch rule %q, expected PATTERN=MODE", rule)
+	}
+	pattern := strings.TrimSpace(rule[:eq])
+	mode := strings.ToLower(strings.TrimSpace(rule[eq+1:]))
+	if mode != FetchBrowser && mode != FetchHTTP {
+		return FetchRule{}, fmt.Errorf("invalid mode %q in fetch rule %q (expected browser or http)", mode, rule)
+	}
+
+	if strings.HasPrefix(pattern, "url:") {
+		re, err := regexp.Compile(strings.TrimPrefix(pattern, "url:"))
+		if err != nil {
+			return FetchRule{}, fmt.Errorf("invalid URL pattern in fetch rule %q: %w", rule, err)
+		}
+		return FetchRule{URLPattern: re, Mode: mode}, nil
+	}
+	if !strings.Contains(pattern, "/") {
+		return FetchRule{}, fmt.Errorf("invalid MIME pattern %q in fetch rule %q", pattern, rule)
+	}
+	return FetchRule{MimePattern: strings.ToLower(pattern), Mode: mode}, nil
+}
+
+// matchMimePattern matches a MIME type against a pattern such as image/*,
+// */json or text/css
+func matchMimePattern(pattern, mimeType string) bool {
+	mimeType = canonicalMimeType(mimeType)
+	if mimeType == "" {
+		return false
+	}
+	matched, err := path.Match(pattern, mimeType)
+	if err == nil && matched {
+		return true
+	}
+	matched, err = path.Match(canonicalMimeType(pattern), mimeType)
+	return err == nil && matched
+}
+
+// ResourceFetcher decides how each resource is fetched and holds the plain
+// HTTP client used outside the browser. It is safe for concurrent use.
+type ResourceFetcher struct {
+	Mode   string
+	Rules  []FetchRule
+	Client *http.Client
+
+	mu        sync.Mutex
+	userAgent string
+}
+
+// NewResourceFetcher creates a fetcher for a mode and rules. Requests made
+// outside the browser go through the proxy, or the environment's proxy when
+// none is given.
+func NewResourceFetcher(mode string, rules []string, proxy string) (*ResourceFetcher, error) {
+	switch mode {
+	case FetchBrowser, FetchHTTP, FetchHybrid:
+	default:
+		return nil, fmt.Errorf("unknown fetch mode %q (expected browser, http or hybrid)", mode)
+	}
+
+	f := &ResourceFetcher{Mode: mode}
+	for _, rule := range rules {
+		parsed, err := parseFetchRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		f.Rules = append(f.Rules, parsed)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q", proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	f.Client = &http.Client{Transport: transport}
+	return f, nil
+}
+
+// ModeFor returns the fetch mode for a resource URL, browser or http. Rules
+// are checked in order. Otherwise hybrid mode leaves URLs that look like
+// pages to the browser and fetches the rest over HTTP.
+func (f *ResourceFetcher) ModeFor(resourceURL string) string {
+	guessed := baseMimeType(mimeTypeFromExtension(resourceURL))
+	for _, rule := range f.Rules {
+		if rule.URLPattern != nil && rule.URLPattern.MatchString(resourceURL) {
+			return rule.Mode
+		}
+		if rule.MimePattern != "" && matchMimePattern(rule.MimePattern, guessed) {
+			return rule.Mode
+		}
+	}
+
+	if f.Mode != FetchHybrid {
+		return f.Mode
+	}
+	if isHTMLMimeType(guessed) {
+		return FetchBrowser
+	}
+	return FetchHTTP
+}
+
+// isHTMLMimeType reports whether a MIME type is an HTML document
+func isHTMLMimeType(mimeType string) bool {
+	mimeType = baseMimeType(mimeType)
+	return mimeType == "text/html" || mimeType == "application/xhtml+xml"
+}
+
+// browserUserAgent returns the browser's user agent. It is looked up until
+// a lookup succeeds, as one made mid-navigation can fail.
+func (f *ResourceFetcher) browserUserAgent(ctx context.Context) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.userAgent == "" {
+		var userAgent string
+		if err := chromedp.Run(ctx, chromedp.Evaluate(`navigator.userAgent`, &userAgent)); err == nil {
+			f.userAgent = userAgent
+		}
+	}
+	return f.userAgent
+}
+
+// browserCookies returns the Cookie header the browser would send to a URL
+func browserCookies(ctx context.Context, resourceURL string) string {
+	var cookies []*network.Cookie
+	err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = network.GetCookies().WithURLs([]string{resourceURL}).Do(ctx)
+		return err
+	}))
+	if err != nil {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(cookies))
+	for _, cookie := range cookies {
+		pairs = append(pairs, cookie.Name+"="+cookie.Value)
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// requestHeaders returns the headers sent to a URL outside the browser: the
+// browser's cookies and user agent when it is running, then the custom
+// headers and interception rules that apply to the URL's host
+func (nc *NetworkCapture) requestHeaders(ctx context.Context, requestURL string) map[string]string {
+	headers := make(map[string]string)
+	if chromedp.FromContext(ctx) != nil {
+		if userAgent := nc.Fetcher.browserUserAgent(ctx); userAgent != "" {
+			headers["User-Agent"] = userAgent
+		}
+		if cookies := browserCookies(ctx, requestURL); cookies != "" {
+			headers["Cookie"] = cookies
+		}
+	}
+	headers, _ = nc.Interceptor.RewriteHeaders(requestURL, headers)
+	return headers
+}
+
+// fetchHTTP fetches a URL with the plain HTTP client, bounded by a timeout.
+// When a browser is running, its cookies and user agent are sent. The
+// interception rules and custom headers apply as they do in the browser.
+func (nc *NetworkCapture) fetchHTTP(ctx context.Context, resourceURL string, timeout time.Duration) (*Exchange, error) {
+	if nc.Interceptor.Blocked(resourceURL, resourceTypeFor(resourceURL)) {
+		return nil, errBlocked
+	}
+	req, err := http.NewRequest(http.MethodGet, resourceURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := nc.requestHeaders(ctx, resourceURL)
+	if mock := nc.Interceptor.Mock(http.MethodGet, resourceURL); mock != nil {
+		return mockExchange(http.MethodGet, resourceURL, headers, mock), nil
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Go copies the first request's headers onto every redirect, so they
+	// are scoped again for the host of each hop
+	client := *nc.Fetcher.Client
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+		for key := range headers {
+			next.Header.Del(key)
+		}
+		for key, value := range nc.requestHeaders(next.Context(), next.URL.String()) {
+			next.Header.Set(key, value)
+		}
+		return nil
+	}
+
+	started := time.Now()
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	exchange := &Exchange{
+		Method:          http.MethodGet,
+		URL:             resourceURL,
+		RequestHeaders:  joinHeaders(req.Header),
+		ResourceType:    string(network.ResourceTypeOther),
+		Status:          resp.StatusCode,
+		StatusText:      strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		Protocol:        strings.ToLower(resp.Proto),
+		ResponseHeaders: joinHeaders(resp.Header),
+		MimeType:        baseMimeType(resp.Header.Get("Content-Type")),
+		Body:            body,
+		Started:         started,
+	}
+	return exchange, nil
+}
+
+// recordExchange passes an exchange made outside the browser to the
+// traffic handlers and the endpoint inventory, as the browser's are
+func (nc *NetworkCapture) recordExchange(exchange *Exchange) {
+	nc.Traffic.Record(exchange)
+	nc.Inventory.AddRequest(exchange.Method, exchange.URL, exchange.Referrer, "", exchange.RequestBody)
+}
+
+// joinHeaders flattens HTTP headers the way the browser reports them,
+// with repeated values joined by newlines
+func joinHeaders(headers http.Header) map[string]string {
+	flat := make(map[string]string, len(headers))
+	for key, values := range headers {
+		flat[key] = strings.Join(values, "\n")
+	}
+	return flat
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFetchRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		wantErr bool
+	}{
+		{"image/*=http", false},
+		{"text/css=browser", false},
+		{"url:^https://example.com/api/=browser", false},
+		{"url:a=b=http", false},
+		{"image/*", true},
+		{"image/*=curl", true},
+		{"css=http", true},
+		{"url:([=http", true},
+		{"=http", true},
+	}
+	for _, tt := range tests {
+		if _, err := parseFetchRule(tt.rule); (err != nil) != tt.wantErr {
+			t.Errorf("parseFetchRule(%q) error = %v, want error %v", tt.rule, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMatchMimePattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		mimeType string
+		want     bool
+	}{
+		{"image/*", "image/png", true},
+		{"image/*", "text/css", false},
+		{"*/json", "application/json", true},
+		{"text/css", "text/css; charset=utf-8", true},
+		{"text/javascript", "application/javascript", true},
+		{"image/*", "", false},
+	}
+	for _, tt := range tests {
+		if got := matchMimePattern(tt.pattern, tt.mimeType); got != tt.want {
+			t.Errorf("matchMimePattern(%q, %q) = %v, want %v", tt.pattern, tt.mimeType, got, tt.want)
+		}
+	}
+}
+
+func TestResourceFetcherModeFor(t *testing.T) {
+	fetcher, err := NewResourceFetcher(FetchHybrid, []string{"url:/api/=browser", "image/*=http"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/api/logo.png", FetchBrowser},
+		{"https://example.com/img/logo.png", FetchHTTP},
+		{"https://example.com/css/site.css", FetchHTTP},
+		{"https://example.com/bundle", FetchHTTP},
+		{"https://example.com/about.html", FetchBrowser},
+	}
+	for _, tt := range tests {
+		if got := fetcher.ModeFor(tt.url); got != tt.want {
+			t.Errorf("ModeFor(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	browser, err := NewResourceFetcher(FetchBrowser, []string{"text/css=http"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := browser.ModeFor("https://example.com/site.css"); got != FetchHTTP {
+		t.Errorf("browser mode with a CSS rule: ModeFor() = %q, want http", got)
+	}
+	if got := browser.ModeFor("https://example.com/app.js"); got != FetchBrowser {
+		t.Errorf("browser mode: ModeFor() = %q, want browser", got)
+	}
+}
+
+func TestNewResourceFetcherRejectsBadInput(t *testing.T) {
+	if _, err := NewResourceFetcher("curl", nil, ""); err == nil {
+		t.Error("unknown fetch mode was accepted")
+	}
+	if _, err := NewResourceFetcher(FetchHybrid, nil, "127.0.0.1"); err == nil {
+		t.Error("proxy without a scheme was accepted")
+	}
+	if _, err := NewResourceFetcher(FetchHybrid, nil, "http://127.0.0.1:8080"); err != nil {
+		t.Errorf("valid proxy rejected: %v", err)
+	}
+}
+
+func TestFetchHTTPScopesHeadersOnRedirect(t *testing.T) {
+	var got http.Header
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer other.Close()
+	// The second server is reached as localhost, a host out of the target's scope
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	var first http.Header
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		first = r.Header.Clone()
+		http.Redirect(w, r, otherURL+"/landing", http.StatusFound)
+	}))
+	defer target.Close()
+
+	interceptor, err := NewInterceptor("", []CustomHeader{
+		{Name: "X-Api-Key", Value: "secret", Hosts: []string{"127.0.0.1"}},
+		{Name: "X-Other", Value: "1", Hosts: []string{"localhost"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher, err := NewResourceFetcher(FetchHTTP, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture := &NetworkCapture{Interceptor: interceptor, Fetcher: fetcher}
+
+	if _, err := capture.fetchHTTP(context.Background(), target.URL+"/start", 5*time.Second); err != nil {
+		t.Fatalf("fetchHTTP() = %v", err)
+	}
+	if first.Get("X-Api-Key") != "secret" || first.Get("X-Other") != "" {
+		t.Errorf("headers sent to the target = %v", first)
+	}
+	if got == nil {
+		t.Fatal("redirect was not followed")
+	}
+	if got.Get("X-Api-Key") != "" {
+		t.Error("custom header scoped to the target was sent to the redirect's host")
+	}
+	if got.Get("X-Other") != "1" {
+		t.Errorf("headers sent after the redirect = %v, want X-Other scoped to its host", got)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// FormInfo represents an HTML form and the fields it submits
+type FormInfo struct {
+	Action string
+	Method string
+	Fields []FormField
+}
+
+// FormField represents a named form control
+type FormField struct {
+	Name  string
+	Type  string
+	Value string
+}
+
+// Helper function to extract forms from HTML content
+func extractForms(htmlContent string, baseURL string) []FormInfo {
+	var forms []FormInfo
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return forms
+	}
+
+	var collectFields func(*html.Node, *FormInfo)
+	collectFields = func(n *html.Node, form *FormInfo) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "input", "select", "textarea", "button":
+				field := FormField{Type: n.Data}
+				for _, attr := range n.Attr {
+					switch attr.Key {
+					case "name":
+						field.Name = attr.Val
+					case "type":
+						field.Type = strings.ToLower(attr.Val)
+					case "value":
+						field.Value = attr.Val
+					}
+				}
+				if field.Name != "" {
+					form.Fields = append(form.Fields, field)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collectFields(c, form)
+		}
+	}
+
+	var extract func(*html.Node)
+	extract = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "form" {
+			form := FormInfo{Action: baseURL, Method: http.MethodGet}
+			for _, attr := range n.Attr {
+				switch attr.Key {
+				case "action":
+					if strings.TrimSpace(attr.Val) != "" {
+						form.Action = resolveURL(strings.TrimSpace(attr.Val), baseURL)
+					}
+				case "method":
+					if strings.EqualFold(attr.Val, http.MethodPost) {
+						form.Method = http.MethodPost
+					}
+				}
+			}
+			collectFields(n, &form)
+			forms = append(forms, form)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			extract(c)
+		}
+	}
+	extract(doc)
+	return forms
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractForms(t *testing.T) {
+	page := `<html><body>
+		<form action="/login" method="post">
+			<input type="text" name="username">
+			<input type="password" name="password">
+			<input type="hidden" name="csrf" value="abc123">
+			<input type="submit" value="Sign in">
+			<select name="remember"><option>yes</option></select>
+		</form>
+		<form>
+			<textarea name="q"></textarea>
+		</form>
+	</body></html>`
+
+	got := extractForms(page, "https://example.com/account/")
+	want := []FormInfo{
+		{
+			Action: "https://example.com/login",
+			Method: "POST",
+			Fields: []FormField{
+				{Name: "username", Type: "text"},
+				{Name: "password", Type: "password"},
+				{Name: "csrf", Type: "hidden", Value: "abc123"},
+				{Name: "remember", Type: "select"},
+			},
+		},
+		{
+			Action: "https://example.com/account/",
+			Method: "GET",
+			Fields: []FormField{{Name: "q", Type: "textarea"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractForms() =\n%+v\nwant\n%+v", got, want)
+	}
+}
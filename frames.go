@@ -0,0 +1,114 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PageFrame is a document of a loaded page, the page itself or one of its
+// iframes, as HTML with its open shadow roots flattened into it
+type PageFrame struct {
+	URL  string
+	HTML string
+}
+
+// flattenDocument renders a DOM tree fetched with DOM.getDocument and pierce
+// as HTML. The content of open shadow roots is placed inside their host
+// element, before its light DOM children. Iframe documents whose URL is in
+// scope become frames of their own, following the document's frame.
+func flattenDocument(document *cdp.Node, inScope func(string) bool) ([]PageFrame, error) {
+	root := &html.Node{Type: html.DocumentNode}
+	var iframes []*cdp.Node
+	for _, child := range document.Children {
+		appendDOMNode(root, child, &iframes)
+	}
+
+	var rendered strings.Builder
+	if err := html.Render(&rendered, root); err != nil {
+		return nil, err
+	}
+	frames := []PageFrame{{URL: document.DocumentURL, HTML: rendered.String()}}
+
+	for _, iframe := range iframes {
+		if !inScope(iframe.DocumentURL) {
+			continue
+		}
+		nested, err := flattenDocument(iframe, inScope)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, nested...)
+	}
+	return frames, nil
+}
+
+// appendDOMNode converts a DOM node and its subtree to HTML nodes under
+// parent, collecting the content documents of iframes
+func appendDOMNode(parent *html.Node, node *cdp.Node, iframes *[]*cdp.Node) {
+	switch node.NodeType {
+	case cdp.NodeTypeDocumentType:
+		parent.AppendChild(&html.Node{Type: html.DoctypeNode, Data: node.NodeName})
+
+	case cdp.NodeTypeText, cdp.NodeTypeCDATA:
+		parent.AppendChild(&html.Node{Type: html.TextNode, Data: node.NodeValue})
+
+	case cdp.NodeTypeComment:
+		parent.AppendChild(&html.Node{Type: html.CommentNode, Data: node.NodeValue})
+
+	case cdp.NodeTypeElement:
+		name := node.LocalName
+		if name == "" {
+			name = strings.ToLower(node.NodeName)
+		}
+		element := &html.Node{Type: html.ElementNode, Data: name, DataAtom: atom.Lookup([]byte(name))}
+		for i := 0; i+1 < len(node.Attributes); i += 2 {
+			element.Attr = append(element.Attr, html.Attribute{Key: node.Attributes[i], Val: node.Attributes[i+1]})
+		}
+		parent.AppendChild(element)
+
+		// Closed and user-agent shadow roots, such as form controls, stay hidden
+		for _, shadowRoot := range node.ShadowRoots {
+			if shadowRoot.ShadowRootType == cdp.ShadowRootTypeOpen {
+				for _, child := range shadowRoot.Children {
+					appendDOMNode(element, child, iframes)
+				}
+			}
+		}
+		for _, child := range node.Children {
+			appendDOMNode(element, child, iframes)
+		}
+		if node.TemplateContent != nil {
+			for _, child := range node.TemplateContent.Children {
+				appendDOMNode(element, child, iframes)
+			}
+		}
+		if node.ContentDocument != nil {
+			*iframes = append(*iframes, node.ContentDocument)
+		}
+	}
+}
+
+// extractFromFrames runs link, resource and form extraction over every
+// frame of a page. The first frame is the page itself and resolves against
+// the page URL; links found in iframes record the frame they came from.
+func extractFromFrames(frames []PageFrame, pageURL string, resolver *URLResolver) (links []LinkInfo, resources []LinkInfo, forms []FormInfo) {
+	for i, frame := range frames {
+		baseURL, frameURL := frame.URL, frame.URL
+		if i == 0 {
+			baseURL, frameURL = pageURL, ""
+		}
+		for _, link := range extractLinksWithMetadata(frame.HTML, baseURL, resolver) {
+			link.Frame = frameURL
+			links = append(links, link)
+		}
+		for _, resource := range extractResources(frame.HTML, baseURL, resolver) {
+			resource.Frame = frameURL
+			resources = append(resources, resource)
+		}
+		forms = append(forms, extractForms(frame.HTML, baseURL)...)
+	}
+	return links, resources, forms
+}
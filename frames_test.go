@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/cdp"
+)
+
+// domElement builds a DOM element node for tests
+func domElement(name string, attributes []string, children ...*cdp.Node) *cdp.Node {
+	return &cdp.Node{NodeType: cdp.NodeTypeElement, NodeName: strings.ToUpper(name), LocalName: name, Attributes: attributes, Children: children}
+}
+
+// domText builds a DOM text node for tests
+func domText(value string) *cdp.Node {
+	return &cdp.Node{NodeType: cdp.NodeTypeText, NodeName: "#text", NodeValue: value}
+}
+
+// domDocument builds a DOM document node for tests
+func domDocument(documentURL string, children ...*cdp.Node) *cdp.Node {
+	return &cdp.Node{NodeType: cdp.NodeTypeDocument, NodeName: "#document", DocumentURL: documentURL, Children: children}
+}
+
+func TestFlattenDocument(t *testing.T) {
+	card := domElement("fixture-card", nil, domElement("span", nil, domText("light")))
+	card.ShadowRoots = []*cdp.Node{
+		{NodeType: cdp.NodeTypeDocumentFragment, ShadowRootType: cdp.ShadowRootTypeOpen, Children: []*cdp.Node{
+			domElement("a", []string{"href", "/shadow.html"}, domText("Shadow link")),
+			domElement("slot", nil),
+		}},
+	}
+	closed := domElement("secret-card", nil)
+	closed.ShadowRoots = []*cdp.Node{
+		{NodeType: cdp.NodeTypeDocumentFragment, ShadowRootType: cdp.ShadowRootTypeClosed, Children: []*cdp.Node{
+			domElement("a", []string{"href", "/closed.html"}),
+		}},
+	}
+	input := domElement("input", []string{"type", "text"})
+	input.ShadowRoots = []*cdp.Node{
+		{NodeType: cdp.NodeTypeDocumentFragment, ShadowRootType: cdp.ShadowRootTypeUserAgent, Children: []*cdp.Node{domElement("div", nil)}},
+	}
+	sameOrigin := domElement("iframe", []string{"src", "/frame.html"})
+	sameOrigin.ContentDocument = domDocument("https://example.com/frame.html",
+		domElement("html", nil, domElement("body", nil, domElement("a", []string{"href", "framed.html"}, domText("Framed link")))))
+	thirdParty := domElement("iframe", []string{"src", "https://ads.invalid/"})
+	thirdParty.ContentDocument = domDocument("https://ads.invalid/",
+		domElement("html", nil, domElement("body", nil, domElement("a", []string{"href", "/ad.html"}))))
+
+	root := domDocument("https://example.com/",
+		&cdp.Node{NodeType: cdp.NodeTypeDocumentType, NodeName: "html"},
+		domElement("html", nil,
+			domElement("head", nil, domElement("style", nil, domText("a > b { background: url(bg.png) }"))),
+			domElement("body", nil, card, closed, input, sameOrigin, thirdParty)))
+
+	frames, err := flattenDocument(root, func(u string) bool { return isSameDomain("example.com", u) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 2 {
+		t.Fatalf("flattenDocument() = %d frames, want the page and one iframe", len(frames))
+	}
+
+	page := frames[0].HTML
+	for _, want := range []string{
+		"<!DOCTYPE html>",
+		`<fixture-card><a href="/shadow.html">Shadow link</a><slot></slot><span>light</span></fixture-card>`,
+		"a > b { background: url(bg.png) }",
+		`<input type="text"/>`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page HTML does not contain %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, "/closed.html") {
+		t.Error("closed shadow root was flattened")
+	}
+	if strings.Contains(page, "framed.html") {
+		t.Error("iframe content was inlined into the page")
+	}
+
+	if frames[1].URL != "https://example.com/frame.html" || !strings.Contains(frames[1].HTML, "Framed link") {
+		t.Errorf("iframe = %+v, want the same-origin frame", frames[1])
+	}
+}
+
+func TestExtractFromFrames(t *testing.T) {
+	frames := []PageFrame{
+		{URL: "https://example.com/redirected", HTML: `<a href="next.html">Next</a><img src="logo.png">`},
+		{URL: "https://example.com/embed/frame.html", HTML: `<a href="framed.html">Framed</a><form action="/search"><input name="q"></form>`},
+	}
+	links, resources, forms := extractFromFrames(frames, "https://example.com/page", nil)
+
+	if len(links) != 2 {
+		t.Fatalf("links = %+v, want 2", links)
+	}
+	if links[0].URL != "https://example.com/next.html" || links[0].Frame != "" {
+		t.Errorf("page link = %+v, want it resolved against the page and no frame", links[0])
+	}
+	if links[1].URL != "https://example.com/embed/framed.html" || links[1].Frame != "https://example.com/embed/frame.html" {
+		t.Errorf("iframe link = %+v, want it resolved against and recording the frame", links[1])
+	}
+	if len(resources) != 1 || resources[0].URL != "https://example.com/logo.png" {
+		t.Errorf("resources = %+v", resources)
+	}
+	if len(forms) != 1 {
+		t.Errorf("forms = %+v, want the iframe's form", forms)
+	}
+}
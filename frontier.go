@@ -0,0 +1,214 @@
+package main
+
+import (
+	"container/heap"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Crawl ordering strategies for the frontier
+const (
+	StrategyBFS      = "bfs"
+	StrategyDFS      = "dfs"
+	StrategyPriority = "priority"
+	StrategyRandom   = "random"
+)
+
+// Frontier holds the requests that are waiting to be crawled
+type Frontier interface {
+	// Push adds a request to the frontier
+	Push(req *Request)
+	// Pop removes and returns the next request to crawl, or nil when empty
+	Pop() *Request
+	// Len returns the number of requests waiting in the frontier
+	Len() int
+}
+
+// NewFrontier creates a frontier for the named strategy
+func NewFrontier(strategy string) (Frontier, error) {
+	switch strings.ToLower(strategy) {
+	case StrategyBFS, "":
+		return &fifoFrontier{}, nil
+	case StrategyDFS:
+		return &lifoFrontier{}, nil
+	case StrategyPriority:
+		return &priorityFrontier{seenSegments: make(map[string]bool)}, nil
+	case StrategyRandom:
+		return &randomFrontier{}, nil
+	}
+	return nil, fmt.Errorf("unknown crawl strategy %q (expected bfs, dfs, priority or random)", strategy)
+}
+
+// fifoFrontier crawls breadth-first
+type fifoFrontier struct {
+	queue []*Request
+}
+
+func (f *fifoFrontier) Push(req *Request) {
+	f.queue = append(f.queue, req)
+}
+
+func (f *fifoFrontier) Pop() *Request {
+	if len(f.queue) == 0 {
+		return nil
+	}
+	req := f.queue[0]
+	f.queue[0] = nil
+	f.queue = f.queue[1:]
+	return req
+}
+
+func (f *fifoFrontier) Len() int {
+	return len(f.queue)
+}
+
+// lifoFrontier crawls depth-first
+type lifoFrontier struct {
+	stack []*Request
+}
+
+func (f *lifoFrontier) Push(req *Request) {
+	f.stack = append(f.stack, req)
+}
+
+func (f *lifoFrontier) Pop() *Request {
+	if len(f.stack) == 0 {
+		return nil
+	}
+	last := len(f.stack) - 1
+	req := f.stack[last]
+	f.stack[last] = nil
+	f.stack = f.stack[:last]
+	return req
+}
+
+func (f *lifoFrontier) Len() int {
+	return len(f.stack)
+}
+
+// randomFrontier crawls in random order
+type randomFrontier struct {
+	pool []*Request
+}
+
+func (f *randomFrontier) Push(req *Request) {
+	f.pool = append(f.pool, req)
+}
+
+func (f *randomFrontier) Pop() *Request {
+	if len(f.pool) == 0 {
+		return nil
+	}
+	i := rand.Intn(len(f.pool))
+	last := len(f.pool) - 1
+	req := f.pool[i]
+	f.pool[i] = f.pool[last]
+	f.pool[last] = nil
+	f.pool = f.pool[:last]
+	return req
+}
+
+func (f *randomFrontier) Len() int {
+	return len(f.pool)
+}
+
+// priorityFrontier crawls the highest scoring requests first.
+// Requests with equal scores are crawled in the order they were pushed.
+type priorityFrontier struct {
+	items        priorityQueue
+	seenSegments map[string]bool
+	seq          int
+}
+
+func (f *priorityFrontier) Push(req *Request) {
+	score := scoreRequest(req, f.seenSegments)
+	heap.Push(&f.items, &priorityItem{req: req, score: score, seq: f.seq})
+	f.seq++
+}
+
+func (f *priorityFrontier) Pop() *Request {
+	if f.items.Len() == 0 {
+		return nil
+	}
+	return heap.Pop(&f.items).(*priorityItem).req
+}
+
+func (f *priorityFrontier) Len() int {
+	return f.items.Len()
+}
+
+type priorityItem struct {
+	req   *Request
+	score int
+	seq   int
+}
+
+// priorityQueue implements heap.Interface as a max-heap on score
+type priorityQueue []*priorityItem
+
+func (pq priorityQueue) Len() int { return len(pq) }
+
+func (pq priorityQueue) Less(i, j int) bool {
+	if pq[i].score != pq[j].score {
+		return pq[i].score > pq[j].score
+	}
+	return pq[i].seq < pq[j].seq
+}
+
+func (pq priorityQueue) Swap(i, j int) { pq[i], pq[j] = pq[j], pq[i] }
+
+func (pq *priorityQueue) Push(x interface{}) {
+	*pq = append(*pq, x.(*priorityItem))
+}
+
+func (pq *priorityQueue) Pop() interface{} {
+	old := *pq
+	last := len(old) - 1
+	item := old[last]
+	old[last] = nil
+	*pq = old[:last]
+	return item
+}
+
+// apiPathPattern matches path segments that usually belong to an API
+var apiPathPattern = regexp.MustCompile(`(?i)(^|/)(api|rest|graphql|gql|rpc|v[0-9]+|ajax|json|ws)(/|$)|\.(json|xml)$`)
+
+// scoreRequest rates how interesting a request is to crawl early.
+// Query parameters, path segments not seen before and API-like paths
+// raise the score; depth lowers it slightly.
+// The segments of the scored URL are added to seenSegments.
+func scoreRequest(req *Request, seenSegments map[string]bool) int {
+	parsedURL, err := url.Parse(req.URL)
+	if err != nil {
+		return 0
+	}
+
+	score := 0
+
+	// Prefer URLs carrying parameters
+	if params := parsedURL.Query(); len(params) > 0 {
+		score += 2 + len(params)
+	}
+
+	// Prefer paths that introduce segments we haven't seen yet
+	for _, segment := range strings.Split(strings.Trim(parsedURL.Path, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		segment = strings.ToLower(segment)
+		if !seenSegments[segment] {
+			seenSegments[segment] = true
+			score += 2
+		}
+	}
+
+	// Prefer API-like endpoints
+	if apiPathPattern.MatchString(parsedURL.Path) {
+		score += 5
+	}
+
+	return score - req.Depth
+}
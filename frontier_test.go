@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// drain pops every request of a frontier and returns their URL paths
+func drain(f Frontier) []string {
+	var order []string
+	for f.Len() > 0 {
+		order = append(order, f.Pop().URL[len("https://example.com"):])
+	}
+	return order
+}
+
+func TestFrontierOrder(t *testing.T) {
+	tests := []struct {
+		strategy string
+		push     []string
+		want     []string
+	}{
+		{"bfs", []string{"/a", "/b", "/c"}, []string{"/a", "/b", "/c"}},
+		{"", []string{"/a", "/b"}, []string{"/a", "/b"}},
+		{"DFS", []string{"/a", "/b", "/c"}, []string{"/c", "/b", "/a"}},
+		// Parameters and API paths first; /docs/a and /docs/b tie after /docs
+		// is seen, and keep their push order
+		{"priority", []string{"/docs", "/docs/a", "/docs/b", "/search?q=1&page=2", "/api/users"},
+			[]string{"/api/users", "/search?q=1&page=2", "/docs", "/docs/a", "/docs/b"}},
+		// A path made only of segments already seen scores below a new one
+		{"priority", []string{"/about", "/about", "/contact"}, []string{"/about", "/contact", "/about"}},
+	}
+	for _, tt := range tests {
+		f, err := NewFrontier(tt.strategy)
+		if err != nil {
+			t.Fatalf("NewFrontier(%q) = %v", tt.strategy, err)
+		}
+		for _, path := range tt.push {
+			f.Push(&Request{URL: "https://example.com" + path})
+		}
+		if got := drain(f); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s order = %v, want %v", tt.strategy, got, tt.want)
+		}
+		if f.Pop() != nil {
+			t.Errorf("%s Pop() on an empty frontier returned a request", tt.strategy)
+		}
+	}
+}
+
+func TestRandomFrontierPopsEveryRequest(t *testing.T) {
+	f, err := NewFrontier("random")
+	if err != nil {
+		t.Fatal(err)
+	}
+	push := []string{"/a", "/b", "/c", "/d"}
+	for _, path := range push {
+		f.Push(&Request{URL: "https://example.com" + path})
+	}
+	got := drain(f)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, push) {
+		t.Errorf("random frontier popped %v, want each of %v once", got, push)
+	}
+}
+
+func TestScoreRequest(t *testing.T) {
+	seen := map[string]bool{"docs": true}
+	tests := []struct {
+		url   string
+		depth int
+		want  int
+	}{
+		{"https://example.com/docs", 0, 0},
+		{"https://example.com/docs?id=1", 0, 3},
+		{"https://example.com/docs/intro", 1, 1},
+		{"https://example.com/api/v2/items.json", 0, 11},
+	}
+	for _, tt := range tests {
+		if got := scoreRequest(&Request{URL: tt.url, Depth: tt.depth}, seen); got != tt.want {
+			t.Errorf("scoreRequest(%q, depth %d) = %d, want %d", tt.url, tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestNewFrontierRejectsUnknownStrategy(t *testing.T) {
+	if _, err := NewFrontier("best-first"); err == nil {
+		t.Error("NewFrontier(\"best-first\") succeeded, want an error")
+	}
+}
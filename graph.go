@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Graph export formats
+const (
+	GraphDOT     = "dot"
+	GraphGraphML = "graphml"
+	GraphJSON    = "json"
+)
+
+// GraphNode is a URL in the site graph
+type GraphNode struct {
+	URL       string `json:"url"`
+	Depth     int    `json:"depth"`
+	Status    int    `json:"status,omitempty"`
+	Crawled   bool   `json:"crawled"`
+	InDegree  int    `json:"in_degree"`
+	OutDegree int    `json:"out_degree"`
+}
+
+// GraphEdge records that a URL was discovered from a page
+type GraphEdge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Tag       string `json:"tag,omitempty"`
+	Attribute string `json:"attribute,omitempty"`
+	Text      string `json:"text,omitempty"`
+
+	Resolution string `json:"resolution,omitempty"`
+	Frame      string `json:"frame,omitempty"`
+}
+
+// SiteGraph is the link graph of the crawl
+type SiteGraph struct {
+	nodes     map[string]*GraphNode
+	edges     []GraphEdge
+	edgeIndex map[string]bool
+}
+
+// NewSiteGraph creates an empty site graph
+func NewSiteGraph() *SiteGraph {
+	return &SiteGraph{
+		nodes:     make(map[string]*GraphNode),
+		edgeIndex: make(map[string]bool),
+	}
+}
+
+// node returns the node for a URL, creating it at the given depth.
+// A node keeps the smallest depth it was seen at.
+func (g *SiteGraph) node(urlStr string, depth int) *GraphNode {
+	n, ok := g.nodes[urlStr]
+	if !ok {
+		n = &GraphNode{URL: urlStr, Depth: depth}
+		g.nodes[urlStr] = n
+	} else if depth < n.Depth {
+		n.Depth = depth
+	}
+	return n
+}
+
+// AddPage records a crawled page and the HTTP status it returned
+func (g *SiteGraph) AddPage(job *Request, status int) {
+	n := g.node(job.URL, job.Depth)
+	n.Crawled = true
+	n.Status = status
+}
+
+// AddLink records a link discovered on a page
+func (g *SiteGraph) AddLink(from *Request, link LinkInfo) {
+	key := from.URL + "\x00" + link.URL + "\x00" + link.Tag + "\x00" + link.Attribute
+	if g.edgeIndex[key] {
+		return
+	}
+	g.edgeIndex[key] = true
+
+	g.node(from.URL, from.Depth).OutDegree++
+	g.node(link.URL, from.Depth+1).InDegree++
+	g.edges = append(g.edges, GraphEdge{
+		From:      from.URL,
+		To:        link.URL,
+		Tag:       link.Tag,
+		Attribute: link.Attribute,
+		Text:      link.Text,
+
+		Resolution: link.Resolution,
+		Frame:      link.Frame,
+	})
+}
+
+// Nodes returns the graph nodes sorted by depth and URL
+func (g *SiteGraph) Nodes() []*GraphNode {
+	nodes := make([]*GraphNode, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Depth != nodes[j].Depth {
+			return nodes[i].Depth < nodes[j].Depth
+		}
+		return nodes[i].URL < nodes[j].URL
+	})
+	return nodes
+}
+
+// parseGraphFormats parses a comma-separated list of graph formats
+func parseGraphFormats(formats string) ([]string, error) {
+	var parsed []string
+	for _, format := range strings.Split(formats, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		switch format {
+		case "":
+			continue
+		case GraphDOT, GraphGraphML, GraphJSON:
+			parsed = appendUnique(parsed, format)
+		default:
+			return nil, fmt.Errorf("unknown graph format %q (expected dot, graphml or json)", format)
+		}
+	}
+	return parsed, nil
+}
+
+// SaveGraph writes the site graph in each requested format
+func (nc *NetworkCapture) SaveGraph() {
+	if len(nc.GraphFormats) == 0 || len(nc.Graph.nodes) == 0 {
+		return
+	}
+
+	for _, format := range nc.GraphFormats {
+		var data []byte
+		var err error
+		switch format {
+		case GraphDOT:
+			data = []byte(nc.Graph.DOT())
+		case GraphGraphML:
+			data, err = nc.Graph.GraphML()
+		case GraphJSON:
+			data, err = json.MarshalIndent(struct {
+				Nodes []*GraphNode `json:"nodes"`
+				Edges []GraphEdge  `json:"edges"`
+			}{nc.Graph.Nodes(), nc.Graph.edges}, "", "  ")
+		}
+		if err != nil {
+			slog.Error("Failed to encode graph", "format", format, "error", err)
+			continue
+		}
+
+		graphFile := filepath.Join(nc.OutputDir, "graph."+format)
+		if err := os.WriteFile(graphFile, data, 0644); err != nil {
+			slog.Error("Failed to write graph", "format", format, "error", err)
+			continue
+		}
+	}
+	slog.Info("Saved site graph", "nodes", len(nc.Graph.nodes), "edges", len(nc.Graph.edges), "formats", strings.Join(nc.GraphFormats, ","))
+}
+
+// DOT renders the graph in Graphviz DOT format
+func (g *SiteGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph site {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontsize=10];\n")
+	for _, n := range g.Nodes() {
+		label := fmt.Sprintf("%s\ndepth %d", n.URL, n.Depth)
+		if n.Status != 0 {
+			label += fmt.Sprintf(", status %d", n.Status)
+		}
+		style := ""
+		if !n.Crawled {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s [label=%s%s];\n", dotQuote(n.URL), dotQuote(label), style)
+	}
+	for _, e := range g.edges {
+		label := e.Tag
+		if e.Text != "" {
+			label += ": " + e.Text
+		}
+		style := ""
+		if e.Resolution == ResolutionSpeculative {
+			style = ", style=dotted"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(label), style)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote quotes a DOT identifier or label. Newlines become label line breaks.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// GraphML renders the graph in GraphML format
+func (g *SiteGraph) GraphML() ([]byte, error) {
+	type graphmlData struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	type graphmlKey struct {
+		ID   string `xml:"id,attr"`
+		For  string `xml:"for,attr"`
+		Name string `xml:"attr.name,attr"`
+		Type string `xml:"attr.type,attr"`
+	}
+	type graphmlNode struct {
+		ID   string        `xml:"id,attr"`
+		Data []graphmlData `xml:"data"`
+	}
+	type graphmlEdge struct {
+		Source string        `xml:"source,attr"`
+		Target string        `xml:"target,attr"`
+		Data   []graphmlData `xml:"data"`
+	}
+	type graphmlGraph struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphmlNode `xml:"node"`
+		Edges       []graphmlEdge `xml:"edge"`
+	}
+	type graphmlDoc struct {
+		XMLName xml.Name     `xml:"graphml"`
+		XMLNS   string       `xml:"xmlns,attr"`
+		Keys    []graphmlKey `xml:"key"`
+		Graph   graphmlGraph `xml:"graph"`
+	}
+
+	doc := graphmlDoc{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphmlKey{
+			{ID: "url", For: "node", Name: "url", Type: "string"},
+			{ID: "depth", For: "node", Name: "depth", Type: "int"},
+			{ID: "status", For: "node", Name: "status", Type: "int"},
+			{ID: "crawled", For: "node", Name: "crawled", Type: "boolean"},
+			{ID: "tag", For: "edge", Name: "tag", Type: "string"},
+			{ID: "attribute", For: "edge", Name: "attribute", Type: "string"},
+			{ID: "text", For: "edge", Name: "text", Type: "string"},
+			{ID: "resolution", For: "edge", Name: "resolution", Type: "string"},
+			{ID: "frame", For: "edge", Name: "frame", Type: "string"},
+		},
+		Graph: graphmlGraph{ID: "site", EdgeDefault: "directed"},
+	}
+	for _, n := range g.Nodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{
+			ID: n.URL,
+			Data: []graphmlData{
+				{Key: "url", Value: n.URL},
+				{Key: "depth", Value: fmt.Sprint(n.Depth)},
+				{Key: "status", Value: fmt.Sprint(n.Status)},
+				{Key: "crawled", Value: fmt.Sprint(n.Crawled)},
+			},
+		})
+	}
+	for _, e := range g.edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{
+			Source: e.From,
+			Target: e.To,
+			Data: []graphmlData{
+				{Key: "tag", Value: e.Tag},
+				{Key: "attribute", Value: e.Attribute},
+				{Key: "text", Value: e.Text},
+				{Key: "resolution", Value: e.Resolution},
+				{Key: "frame", Value: e.Frame},
+			},
+		})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
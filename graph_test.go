@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestDotQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"https://example.com/", `"https://example.com/"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\path\`, `"C:\\path\\"`},
+		{`\"`, `"\\\""`},
+		{"line one\nline two", `"line one\nline two"`},
+	}
+	for _, tt := range tests {
+		if got := dotQuote(tt.in); got != tt.want {
+			t.Errorf("dotQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// allHosts is the -header-scope value that sends a header to every host
+const allHosts = "*"
+
+// CustomHeader is a -H header and the hosts it is sent to. With Subdomains,
+// as given by -header-scope, hosts match their subdomains too.
+type CustomHeader struct {
+	Name       string
+	Value      string
+	Hosts      []string
+	Subdomains bool
+}
+
+// AppliesTo reports whether the header is sent to a host
+func (h CustomHeader) AppliesTo(host string) bool {
+	for _, scope := range h.Hosts {
+		switch {
+		case scope == allHosts:
+			return true
+		case h.Subdomains && isSameOrSubdomain(scope, host):
+			return true
+		case normalizeHost(host) == normalizeHost(scope):
+			return true
+		}
+	}
+	return false
+}
+
+// headerFlags collects the -H headers in order, with the -header-scope
+// hosts given after each of them
+type headerFlags struct {
+	headers []string
+	scopes  map[int][]string
+}
+
+func (f *headerFlags) String() string {
+	return strings.Join(f.headers, ",")
+}
+
+func (f *headerFlags) Set(value string) error {
+	f.headers = append(f.headers, value)
+	return nil
+}
+
+// headerScopeFlag scopes the -H header given just before it
+type headerScopeFlag struct {
+	*headerFlags
+}
+
+func (f headerScopeFlag) String() string {
+	return ""
+}
+
+func (f headerScopeFlag) Set(value string) error {
+	if len(f.headers) == 0 {
+		return fmt.Errorf("-header-scope must follow the -H header it scopes")
+	}
+	if f.scopes == nil {
+		f.scopes = make(map[int][]string)
+	}
+	last := len(f.headers) - 1
+	for _, host := range strings.Split(value, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if host != allHosts {
+			host = normalizeHost(host)
+		}
+		f.scopes[last] = append(f.scopes[last], host)
+	}
+	return nil
+}
+
+// parseCustomHeaders parses the -H headers. Headers without a -header-scope
+// are only sent to the target host, the same hosts that are crawled.
+func parseCustomHeaders(flags *headerFlags, targetHost string) []CustomHeader {
+	var headers []CustomHeader
+	for i, header := range flags.headers {
+		parts := strings.SplitN(header, ":", 2)
+		if len(parts) != 2 {
+			slog.Warn("Invalid header format, expected 'Key: Value'", "header", header)
+			continue
+		}
+		header := CustomHeader{
+			Name:       strings.TrimSpace(parts[0]),
+			Value:      strings.TrimSpace(parts[1]),
+			Hosts:      flags.scopes[i],
+			Subdomains: true,
+		}
+		if len(header.Hosts) == 0 {
+			header.Hosts = []string{targetHost}
+			header.Subdomains = false
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+// customHeadersFor returns the custom headers sent to a URL
+func customHeadersFor(headers []CustomHeader, requestURL string) map[string]string {
+	scoped := make(map[string]string)
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		return scoped
+	}
+	for _, header := range headers {
+		if header.AppliesTo(parsedURL.Host) {
+			scoped[header.Name] = header.Value
+		}
+	}
+	return scoped
+}
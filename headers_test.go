@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestParseCustomHeaders(t *testing.T) {
+	fs := flag.NewFlagSet("crawler", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	headers := &headerFlags{scopes: make(map[int][]string)}
+	fs.Var(headers, "H", "")
+	fs.Var(headerScopeFlag{headers}, "header-scope", "")
+
+	args := []string{
+		"-H", "User-Agent: MyBot",
+		"-H", "Authorization: Bearer x", "-header-scope", "API.example.com, www.auth.example.com",
+		"-H", "X-Trace: 1", "-header-scope", "*",
+		"-H", "not a header",
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	parsed := parseCustomHeaders(headers, "example.com")
+	if len(parsed) != 3 {
+		t.Fatalf("parseCustomHeaders() = %v, want 3 headers", parsed)
+	}
+
+	tests := []struct {
+		header int
+		host   string
+		want   bool
+	}{
+		{0, "example.com", true},
+		{0, "www.example.com:8443", true},
+		{0, "cdn.example.com", false},
+		{0, "cdn.jsdelivr.net", false},
+		{1, "api.example.com", true},
+		{1, "v2.api.example.com", true},
+		{1, "auth.example.com", true},
+		{1, "example.com", false},
+		{2, "analytics.google.com", true},
+	}
+	for _, tt := range tests {
+		header := parsed[tt.header]
+		if got := header.AppliesTo(tt.host); got != tt.want {
+			t.Errorf("%s.AppliesTo(%q) = %v, want %v", header.Name, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestHeaderScopeWithoutHeader(t *testing.T) {
+	fs := flag.NewFlagSet("crawler", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	headers := &headerFlags{scopes: make(map[int][]string)}
+	fs.Var(headers, "H", "")
+	fs.Var(headerScopeFlag{headers}, "header-scope", "")
+
+	if err := fs.Parse([]string{"-header-scope", "api.example.com", "-H", "X-Test: 1"}); err == nil {
+		t.Error("-header-scope before any -H was accepted")
+	}
+}
+
+func TestCustomHeadersFor(t *testing.T) {
+	headers := []CustomHeader{
+		{Name: "Authorization", Value: "Bearer x", Hosts: []string{"api.example.com"}, Subdomains: true},
+		{Name: "User-Agent", Value: "MyBot", Hosts: []string{"example.com"}, Subdomains: true},
+	}
+	got := customHeadersFor(headers, "https://api.example.com/v1/users")
+	if len(got) != 2 {
+		t.Errorf("headers for api.example.com = %v, want both", got)
+	}
+	got = customHeadersFor(headers, "https://www.example.com/")
+	if len(got) != 1 || got["User-Agent"] != "MyBot" {
+		t.Errorf("headers for www.example.com = %v, want only User-Agent", got)
+	}
+	if got := customHeadersFor(headers, "https://cdn.other.net/lib.js"); len(got) != 0 {
+		t.Errorf("headers for a third-party host = %v, want none", got)
+	}
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chromeNames are the browser executables chromedp looks for
+var chromeNames = []string{
+	"headless_shell", "headless-shell", "chromium", "chromium-browser",
+	"google-chrome", "google-chrome-stable", "google-chrome-beta", "google-chrome-unstable",
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+}
+
+// requireChrome skips the test unless a Chrome or Chromium browser is installed
+func requireChrome(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping browser test in short mode")
+	}
+	for _, name := range chromeNames {
+		if _, err := exec.LookPath(name); err == nil {
+			return
+		}
+	}
+	t.Skip("Chrome or Chromium not found")
+}
+
+// fixturePNG returns a small PNG image, which contains NUL bytes
+func fixturePNG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for x := 0; x < 4; x++ {
+		img.Set(x, x, color.RGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newFixtureServer serves testdata/site plus a redirect and binary images
+func newFixtureServer(t *testing.T, logo []byte) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(filepath.Join("testdata", "site"))))
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/target.html", http.StatusFound)
+	})
+	for _, path := range []string{"/logo.png", "/img/banner.png", "/img/hero.png"} {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(logo)
+		})
+	}
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// readJSON decodes a JSON output file of the crawl
+func readJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", filepath.Base(path), err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("failed to decode %s: %v", filepath.Base(path), err)
+	}
+}
+
+func TestCrawlFixtureSite(t *testing.T) {
+	requireChrome(t)
+	testCrawlFixtureSite(t, EngineChrome)
+}
+
+func TestCrawlFixtureSiteHTTPEngine(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crawl test in short mode")
+	}
+	testCrawlFixtureSite(t, EngineHTTP)
+}
+
+// testCrawlFixtureSite crawls the fixture site with an engine and checks the output
+func testCrawlFixtureSite(t *testing.T, engine string) {
+	logo := fixturePNG(t)
+	server := newFixtureServer(t, logo)
+	outputDir := t.TempDir()
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-q", "-engine", engine, "-depth", "2", "-retries", "1", "-timeout", "3m", "-graph", "json", server.URL + "/", outputDir}
+	if err := run(args, &stdout, &stderr); err != nil {
+		t.Fatalf("crawl failed: %v\n%s", err, stderr.String())
+	}
+
+	var index []BlobIndexEntry
+	readJSON(t, filepath.Join(outputDir, "index.json"), &index)
+	entries := make(map[string][]BlobIndexEntry)
+	for _, entry := range index {
+		path := strings.TrimPrefix(entry.URL, server.URL)
+		entries[path] = append(entries[path], entry)
+	}
+	blob := func(t *testing.T, path string) []byte {
+		t.Helper()
+		if len(entries[path]) == 0 {
+			t.Fatalf("%s was not captured; captured %v", path, keys(entries))
+		}
+		data, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(entries[path][0].Blob)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	t.Run("nested links", func(t *testing.T) {
+		for _, path := range []string{"/", "/nested/", "/nested/deep.html"} {
+			if len(entries[path]) == 0 {
+				t.Errorf("%s was not captured", path)
+			}
+		}
+	})
+
+	t.Run("redirects", func(t *testing.T) {
+		if !bytes.Contains(blob(t, "/redirect"), []byte("Redirect target")) {
+			t.Error("redirected page does not hold the target's content")
+		}
+	})
+
+	t.Run("script-rendered links", func(t *testing.T) {
+		if engine != EngineChrome {
+			t.Skip("scripts only run in the browser")
+		}
+		if !bytes.Contains(blob(t, "/rendered.html"), []byte("script-rendered link")) {
+			t.Error("page linked only from script-rendered HTML was not crawled")
+		}
+	})
+
+	t.Run("shadow dom and iframes", func(t *testing.T) {
+		if engine != EngineChrome {
+			t.Skip("scripts and iframes only run in the browser")
+		}
+		if !bytes.Contains(blob(t, "/components.html"), []byte("<fixture-card></fixture-card>")) {
+			t.Error("saved page is not the page's own HTML, with its shadow roots left out")
+		}
+		if !bytes.Contains(blob(t, "/shadow.html"), []byte("shadow DOM link")) {
+			t.Error("page linked only from a shadow root was not crawled")
+		}
+		if !bytes.Contains(blob(t, "/framed.html"), []byte("inside an iframe")) {
+			t.Error("page linked only from an iframe was not crawled")
+		}
+
+		var graph struct {
+			Edges []GraphEdge `json:"edges"`
+		}
+		readJSON(t, filepath.Join(outputDir, "graph.json"), &graph)
+		for _, edge := range graph.Edges {
+			if edge.To == server.URL+"/framed.html" {
+				if edge.From != server.URL+"/components.html" || edge.Frame != server.URL+"/frame.html" {
+					t.Errorf("iframe link edge = %+v", edge)
+				}
+				return
+			}
+		}
+		t.Error("no graph edge for the iframe link")
+	})
+
+	t.Run("api calls", func(t *testing.T) {
+		if engine != EngineChrome {
+			t.Skip("scripts only run in the browser")
+		}
+		var calls []Request
+		readJSON(t, filepath.Join(outputDir, "api_calls.json"), &calls)
+		for _, call := range calls {
+			if call.Method == http.MethodPost && strings.HasSuffix(call.URL, "/api/ping?from=js") {
+				if call.Source != server.URL+"/js.html" || call.Body != `{"hello":"world"}` || !strings.HasPrefix(call.Raw, "POST /api/ping?from=js HTTP/1.1") {
+					t.Errorf("API call = %+v", call)
+				}
+				return
+			}
+		}
+		t.Errorf("POST /api/ping missing from API calls %+v", calls)
+	})
+
+	t.Run("binary resources", func(t *testing.T) {
+		if !bytes.Equal(blob(t, "/logo.png"), logo) {
+			t.Error("captured PNG differs from the served bytes")
+		}
+		if mimeType := entries["/logo.png"][0].MimeType; mimeType != "image/png" {
+			t.Errorf("PNG MIME type = %q, want image/png", mimeType)
+		}
+		if len(entries["/app.js"]) == 0 || len(entries["/style.css"]) == 0 {
+			t.Error("script or stylesheet was not captured")
+		}
+	})
+
+	t.Run("css references", func(t *testing.T) {
+		for _, path := range []string{"/theme.css", "/img/banner.png", "/img/hero.png"} {
+			if len(entries[path]) == 0 {
+				t.Errorf("%s, referenced from CSS, was not captured", path)
+			}
+		}
+	})
+
+	t.Run("source maps", func(t *testing.T) {
+		var sources []SourceFile
+		readJSON(t, filepath.Join(outputDir, "sources.json"), &sources)
+		if len(sources) != 2 {
+			t.Fatalf("reconstructed %d sources, want 2", len(sources))
+		}
+		for _, source := range sources {
+			if !strings.HasSuffix(source.Path, "/src/api/client.js") {
+				continue
+			}
+			client, err := os.ReadFile(filepath.Join(outputDir, filepath.FromSlash(source.Path)))
+			if err != nil || !bytes.Contains(client, []byte("/api/items")) {
+				t.Errorf("src/api/client.js was not reconstructed: %v", err)
+			}
+		}
+
+		var graph struct {
+			Nodes []GraphNode `json:"nodes"`
+		}
+		readJSON(t, filepath.Join(outputDir, "graph.json"), &graph)
+		crawled := false
+		for _, node := range graph.Nodes {
+			crawled = crawled || (strings.HasPrefix(node.URL, server.URL+"/api/items") && node.Crawled)
+		}
+		if !crawled {
+			t.Error("/api/items from the original sources was not crawled")
+		}
+
+		var endpoints []Endpoint
+		readJSON(t, filepath.Join(outputDir, "endpoints.json"), &endpoints)
+		for _, endpoint := range endpoints {
+			if endpoint.Path == "/api/items" {
+				return
+			}
+		}
+		t.Error("/api/items from the original sources is missing from the endpoint inventory")
+	})
+
+	t.Run("loops", func(t *testing.T) {
+		for _, path := range []string{"/loop-a.html", "/loop-b.html"} {
+			if got := len(entries[path]); got != 1 {
+				t.Errorf("%s captured %d times, want once", path, got)
+			}
+		}
+	})
+
+	t.Run("forms", func(t *testing.T) {
+		var endpoints []Endpoint
+		readJSON(t, filepath.Join(outputDir, "endpoints.json"), &endpoints)
+		for _, endpoint := range endpoints {
+			if endpoint.Method == http.MethodPost && endpoint.Path == "/login" {
+				return
+			}
+		}
+		t.Error("login form was not recorded as a POST /login endpoint")
+	})
+
+	t.Run("out of scope", func(t *testing.T) {
+		if len(entries["https://external.invalid/"]) > 0 {
+			t.Error("external link was crawled")
+		}
+		var summary CrawlSummary
+		readJSON(t, filepath.Join(outputDir, "summary.json"), &summary)
+		found := false
+		for _, host := range summary.OutOfScopeHosts {
+			found = found || host.Host == "external.invalid"
+		}
+		if !found {
+			t.Errorf("external.invalid missing from out-of-scope hosts %v", summary.OutOfScopeHosts)
+		}
+	})
+
+	t.Run("stdout stream", func(t *testing.T) {
+		streamed := make(map[string]bool)
+		for _, line := range strings.Split(strings.TrimSpace(stdout.String()), "\n") {
+			if !strings.HasPrefix(line, server.URL) {
+				t.Errorf("unexpected stdout line %q", line)
+			}
+			streamed[line] = true
+		}
+		for _, entry := range index {
+			if !streamed[entry.URL] {
+				t.Errorf("%s is indexed but was not written to stdout", entry.URL)
+			}
+		}
+	})
+}
+
+// keys lists the paths captured, for failure messages
+func keys(entries map[string][]BlobIndexEntry) []string {
+	var paths []string
+	for path := range entries {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestRunRestoresLoggerAndWritesSummaryToStderr(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping crawl test in short mode")
+	}
+	server := newFixtureServer(t, fixturePNG(t))
+	outputDir := t.TempDir()
+	logFile := filepath.Join(t.TempDir(), "crawl.log")
+
+	before := slog.Default()
+	var stdout, stderr bytes.Buffer
+	args := []string{"-engine", "http", "-depth", "0", "-retries", "1", "-log-file", logFile, server.URL + "/target.html", outputDir}
+	if err := run(args, &stdout, &stderr); err != nil {
+		t.Fatalf("crawl failed: %v\n%s", err, stderr.String())
+	}
+
+	if slog.Default() != before {
+		t.Error("run left its logger as the default after closing the log file")
+	}
+	if !strings.Contains(stderr.String(), "Crawl summary for") {
+		t.Errorf("summary table was not written to the stderr passed to run: %q", stderr.String())
+	}
+}
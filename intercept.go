@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// errBlocked reports a request stopped by an interception rule
+var errBlocked = errors.New("blocked by interception rule")
+
+// InterceptRules configures request interception. They are loaded from a
+// JSON file and apply to the browser and the HTTP client alike.
+type InterceptRules struct {
+	Block   BlockRules   `json:"block"`
+	Headers []HeaderRule `json:"headers"`
+	Mocks   []MockRule   `json:"mocks"`
+}
+
+// BlockRules stops requests by resource type, such as Image or Media,
+// or by URL regular expression
+type BlockRules struct {
+	ResourceTypes []string `json:"resource_types"`
+	URLs          []string `json:"urls"`
+}
+
+// HeaderRule rewrites the request headers sent to a host and its subdomains
+type HeaderRule struct {
+	Host   string            `json:"host"`
+	Set    map[string]string `json:"set"`
+	Remove []string          `json:"remove"`
+}
+
+// MockRule serves a canned response for requests whose URL matches a
+// regular expression, optionally only for one method
+type MockRule struct {
+	URL      string            `json:"url"`
+	Method   string            `json:"method"`
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers"`
+	Body     string            `json:"body"`
+	BodyFile string            `json:"body_file"`
+
+	re   *regexp.Regexp
+	body []byte
+}
+
+// Interceptor applies interception rules, and the -H custom headers,
+// to outgoing requests. It is safe for concurrent use.
+type Interceptor struct {
+	blockTypes map[string]bool
+	blockURLs  []*regexp.Regexp
+	headers    []HeaderRule
+	mocks      []*MockRule
+	custom     []CustomHeader
+}
+
+// NewInterceptor loads interception rules from a JSON file, if given.
+// Custom headers are sent to the hosts they are scoped to.
+func NewInterceptor(rulesFile string, customHeaders []CustomHeader) (*Interceptor, error) {
+	interceptor := &Interceptor{blockTypes: make(map[string]bool), custom: customHeaders}
+	if rulesFile == "" {
+		return interceptor, nil
+	}
+
+	data, err := os.ReadFile(rulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read interception rules: %w", err)
+	}
+	var rules InterceptRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse interception rules %s: %w", rulesFile, err)
+	}
+
+	for _, resourceType := range rules.Block.ResourceTypes {
+		interceptor.blockTypes[strings.ToLower(resourceType)] = true
+	}
+	for _, pattern := range rules.Block.URLs {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block pattern %q: %w", pattern, err)
+		}
+		interceptor.blockURLs = append(interceptor.blockURLs, re)
+	}
+	for _, rule := range rules.Headers {
+		if rule.Host == "" {
+			return nil, fmt.Errorf("header rule without a host in %s", rulesFile)
+		}
+		rule.Host = normalizeHost(rule.Host)
+		interceptor.headers = append(interceptor.headers, rule)
+	}
+	for i := range rules.Mocks {
+		mock := &rules.Mocks[i]
+		mock.re, err = regexp.Compile(mock.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mock pattern %q: %w", mock.URL, err)
+		}
+		mock.body = []byte(mock.Body)
+		if mock.BodyFile != "" {
+			// Body files are relative to the rules file
+			bodyFile := mock.BodyFile
+			if !filepath.IsAbs(bodyFile) {
+				bodyFile = filepath.Join(filepath.Dir(rulesFile), bodyFile)
+			}
+			mock.body, err = os.ReadFile(bodyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read mock body: %w", err)
+			}
+		}
+		if mock.Status == 0 {
+			mock.Status = http.StatusOK
+		}
+		interceptor.mocks = append(interceptor.mocks, mock)
+	}
+	return interceptor, nil
+}
+
+// Active reports whether requests need to be intercepted at all
+func (i *Interceptor) Active() bool {
+	return len(i.blockTypes) > 0 || len(i.blockURLs) > 0 || len(i.headers) > 0 || len(i.mocks) > 0 || len(i.custom) > 0
+}
+
+// Blocked reports whether a request for a URL and resource type is blocked
+func (i *Interceptor) Blocked(requestURL, resourceType string) bool {
+	if i.blockTypes[strings.ToLower(resourceType)] {
+		return true
+	}
+	for _, re := range i.blockURLs {
+		if re.MatchString(requestURL) {
+			return true
+		}
+	}
+	return false
+}
+
+// Mock returns the first mock matching a request, or nil
+func (i *Interceptor) Mock(method, requestURL string) *MockRule {
+	for _, mock := range i.mocks {
+		if (mock.Method == "" || strings.EqualFold(mock.Method, method)) && mock.re.MatchString(requestURL) {
+			return mock
+		}
+	}
+	return nil
+}
+
+// RewriteHeaders returns the headers to send to a URL: the custom headers
+// scoped to its host are added, then the rules for its host remove and set
+// headers in order.
+// It reports whether anything changed.
+func (i *Interceptor) RewriteHeaders(requestURL string, headers map[string]string) (map[string]string, bool) {
+	parsedURL, err := url.Parse(requestURL)
+	if err != nil {
+		return headers, false
+	}
+	host := normalizeHost(parsedURL.Host)
+
+	rewritten := make(map[string]string, len(headers))
+	for name, value := range headers {
+		rewritten[name] = value
+	}
+	changed := false
+	for _, header := range i.custom {
+		if header.AppliesTo(host) {
+			changed = setHeader(rewritten, header.Name, header.Value) || changed
+		}
+	}
+	for _, rule := range i.headers {
+		if !isSameOrSubdomain(rule.Host, host) {
+			continue
+		}
+		for _, name := range rule.Remove {
+			changed = removeHeader(rewritten, name) || changed
+		}
+		for name, value := range rule.Set {
+			changed = setHeader(rewritten, name, value) || changed
+		}
+	}
+	return rewritten, changed
+}
+
+// setHeader sets a header, replacing any spelling of its name, and reports
+// whether the headers changed
+func setHeader(headers map[string]string, name, value string) bool {
+	for key, existing := range headers {
+		if strings.EqualFold(key, name) {
+			if key == name && existing == value {
+				return false
+			}
+			delete(headers, key)
+		}
+	}
+	headers[name] = value
+	return true
+}
+
+// removeHeader deletes a header case-insensitively and reports whether it was present
+func removeHeader(headers map[string]string, name string) bool {
+	removed := false
+	for key := range headers {
+		if strings.EqualFold(key, name) {
+			delete(headers, key)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// resourceTypeFor guesses the browser resource type of a URL from its
+// extension, for requests made outside the browser
+func resourceTypeFor(requestURL string) string {
+	mimeType := canonicalMimeType(mimeTypeFromExtension(requestURL))
+	switch {
+	case mimeType == "text/css":
+		return string(network.ResourceTypeStylesheet)
+	case mimeType == "application/javascript":
+		return string(network.ResourceTypeScript)
+	case strings.HasPrefix(mimeType, "image/"):
+		return string(network.ResourceTypeImage)
+	case strings.HasPrefix(mimeType, "font/"):
+		return string(network.ResourceTypeFont)
+	case strings.HasPrefix(mimeType, "video/"), strings.HasPrefix(mimeType, "audio/"):
+		return string(network.ResourceTypeMedia)
+	case mimeType == "" || isHTMLMimeType(mimeType):
+		// Paths without an extension are usually pages
+		return string(network.ResourceTypeDocument)
+	}
+	return string(network.ResourceTypeOther)
+}
+
+// headerEntries converts headers to CDP header entries in a stable order
+func headerEntries(headers map[string]string) []*fetch.HeaderEntry {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]*fetch.HeaderEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, &fetch.HeaderEntry{Name: name, Value: headers[name]})
+	}
+	return entries
+}
+
+// interceptRequests pauses every browser request to block, mock or rewrite it
+func (nc *NetworkCapture) interceptRequests(ctx context.Context) error {
+	interceptor := nc.Interceptor
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		paused, ok := ev.(*fetch.EventRequestPaused)
+		if !ok || paused.Request == nil {
+			return
+		}
+
+		// Commands cannot be issued from the listener itself
+		go func() {
+			c := chromedp.FromContext(ctx)
+			executorCtx := cdp.WithExecutor(ctx, c.Target)
+			req := paused.Request
+
+			var err error
+			if interceptor.Blocked(req.URL, string(paused.ResourceType)) {
+				slog.Debug("Blocked request", "url", req.URL, "type", paused.ResourceType)
+				err = fetch.FailRequest(paused.RequestID, network.ErrorReasonBlockedByClient).Do(executorCtx)
+			} else if mock := interceptor.Mock(req.Method, req.URL); mock != nil {
+				slog.Debug("Mocked request", "url", req.URL, "status", mock.Status)
+				err = fetch.FulfillRequest(paused.RequestID, int64(mock.Status)).
+					WithResponseHeaders(headerEntries(mock.Headers)).
+					WithBody(base64.StdEncoding.EncodeToString(mock.body)).
+					Do(executorCtx)
+			} else if headers, changed := interceptor.RewriteHeaders(req.URL, flattenHeaders(req.Headers)); changed {
+				err = fetch.ContinueRequest(paused.RequestID).WithHeaders(headerEntries(headers)).Do(executorCtx)
+			} else {
+				err = fetch.ContinueRequest(paused.RequestID).Do(executorCtx)
+			}
+			if err != nil && ctx.Err() == nil {
+				slog.Debug("Failed to resume intercepted request", "url", req.URL, "error", err)
+			}
+		}()
+	})
+	return chromedp.Run(ctx, fetch.Enable())
+}
+
+// mockExchange builds the exchange for a mocked request made outside the browser
+func mockExchange(method, requestURL string, headers map[string]string, mock *MockRule) *Exchange {
+	responseHeaders := make(map[string]string, len(mock.Headers))
+	for name, value := range mock.Headers {
+		responseHeaders[http.CanonicalHeaderKey(name)] = value
+	}
+	return &Exchange{
+		Method:          method,
+		URL:             requestURL,
+		RequestHeaders:  headers,
+		ResourceType:    resourceTypeFor(requestURL),
+		Status:          mock.Status,
+		StatusText:      http.StatusText(mock.Status),
+		Protocol:        "http/1.1",
+		ResponseHeaders: responseHeaders,
+		MimeType:        baseMimeType(responseHeaders["Content-Type"]),
+		Body:            mock.body,
+		Started:         time.Now(),
+	}
+}
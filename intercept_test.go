@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeInterceptRules writes a rules file and a mock body next to it
+func writeInterceptRules(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	rules := `{
+  "block": {"resource_types": ["Image", "media"], "urls": ["/ads/"]},
+  "headers": [
+    {"host": "api.example.com", "set": {"Authorization": "Bearer token"}, "remove": ["Cookie"]}
+  ],
+  "mocks": [
+    {"url": "/api/config$", "method": "GET", "headers": {"content-type": "application/json"}, "body_file": "config.json"},
+    {"url": "/api/fail", "status": 503, "body": "down"}
+  ]
+}`
+	if err := os.WriteFile(filepath.Join(dir, "rules.json"), []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"debug":true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "rules.json")
+}
+
+func TestNewInterceptorRejectsBadRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+	}{
+		{"malformed json", `{"block":`},
+		{"bad block pattern", `{"block": {"urls": ["(["]}}`},
+		{"header rule without host", `{"headers": [{"set": {"X-Test": "1"}}]}`},
+		{"bad mock pattern", `{"mocks": [{"url": "(["}]}`},
+		{"missing body file", `{"mocks": [{"url": "/x", "body_file": "missing.json"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesFile := filepath.Join(t.TempDir(), "rules.json")
+			if err := os.WriteFile(rulesFile, []byte(tt.rules), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := NewInterceptor(rulesFile, nil); err == nil {
+				t.Errorf("NewInterceptor(%s) succeeded, want an error", tt.rules)
+			}
+		})
+	}
+}
+
+func TestInterceptorActive(t *testing.T) {
+	interceptor, err := NewInterceptor("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if interceptor.Active() {
+		t.Error("interceptor without rules or headers is active")
+	}
+	interceptor, err = NewInterceptor("", []CustomHeader{{Name: "X-Test", Value: "1", Hosts: []string{allHosts}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !interceptor.Active() {
+		t.Error("interceptor with custom headers is not active")
+	}
+}
+
+func TestInterceptorBlocked(t *testing.T) {
+	interceptor, err := NewInterceptor(writeInterceptRules(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		url          string
+		resourceType string
+		want         bool
+	}{
+		{"https://example.com/logo.png", "Image", true},
+		{"https://example.com/intro.mp4", "Media", true},
+		{"https://example.com/ads/banner.js", "Script", true},
+		{"https://example.com/app.js", "Script", false},
+		{"https://example.com/", "Document", false},
+	}
+	for _, tt := range tests {
+		if got := interceptor.Blocked(tt.url, tt.resourceType); got != tt.want {
+			t.Errorf("Blocked(%q, %q) = %v, want %v", tt.url, tt.resourceType, got, tt.want)
+		}
+	}
+}
+
+func TestInterceptorMock(t *testing.T) {
+	interceptor, err := NewInterceptor(writeInterceptRules(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := interceptor.Mock("GET", "https://example.com/api/config")
+	if mock == nil {
+		t.Fatal("no mock for GET /api/config")
+	}
+	if mock.Status != 200 || string(mock.body) != `{"debug":true}` {
+		t.Errorf("mock = %d %q, want 200 with the body file", mock.Status, mock.body)
+	}
+	if interceptor.Mock("POST", "https://example.com/api/config") != nil {
+		t.Error("GET-only mock matched a POST")
+	}
+	if mock := interceptor.Mock("POST", "https://example.com/api/fail"); mock == nil || mock.Status != 503 {
+		t.Errorf("mock for /api/fail = %v, want status 503", mock)
+	}
+	if interceptor.Mock("GET", "https://example.com/about") != nil {
+		t.Error("unmatched URL was mocked")
+	}
+
+	exchange := mockExchange("GET", "https://example.com/api/config", nil, mock)
+	if exchange.MimeType != "application/json" || exchange.ResponseHeaders["Content-Type"] != "application/json" {
+		t.Errorf("mock exchange MIME type = %q, headers = %v", exchange.MimeType, exchange.ResponseHeaders)
+	}
+}
+
+func TestInterceptorRewriteHeaders(t *testing.T) {
+	interceptor, err := NewInterceptor(writeInterceptRules(t), []CustomHeader{
+		{Name: "X-Crawler", Value: "1", Hosts: []string{allHosts}},
+		{Name: "X-Api-Key", Value: "secret", Hosts: []string{"api.example.com"}, Subdomains: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := map[string]string{"cookie": "session=1", "Accept": "*/*"}
+	rewritten, changed := interceptor.RewriteHeaders("https://v2.api.example.com/users", headers)
+	if !changed {
+		t.Error("headers for api.example.com were not changed")
+	}
+	want := map[string]string{"Accept": "*/*", "X-Crawler": "1", "X-Api-Key": "secret", "Authorization": "Bearer token"}
+	if len(rewritten) != len(want) {
+		t.Errorf("rewritten headers = %v, want %v", rewritten, want)
+	}
+	for name, value := range want {
+		if rewritten[name] != value {
+			t.Errorf("header %s = %q, want %q", name, rewritten[name], value)
+		}
+	}
+	if headers["cookie"] != "session=1" {
+		t.Error("RewriteHeaders modified its input")
+	}
+
+	rewritten, _ = interceptor.RewriteHeaders("https://example.com/", headers)
+	if rewritten["cookie"] != "session=1" || rewritten["Authorization"] != "" || rewritten["X-Api-Key"] != "" {
+		t.Errorf("headers for another host = %v, want only the unscoped custom header added", rewritten)
+	}
+
+	if _, changed := interceptor.RewriteHeaders("https://example.com/", map[string]string{"X-Crawler": "1"}); changed {
+		t.Error("headers already set were reported as changed")
+	}
+}
+
+func TestResourceTypeFor(t *testing.T) {
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/logo.png", "Image"},
+		{"https://example.com/site.css", "Stylesheet"},
+		{"https://example.com/app.js", "Script"},
+		{"https://example.com/font.woff2", "Font"},
+		{"https://example.com/about", "Document"},
+		{"https://example.com/about.html", "Document"},
+		{"https://example.com/data.json", "Other"},
+	}
+	for _, tt := range tests {
+		if got := resourceTypeFor(tt.url); got != tt.want {
+			t.Errorf("resourceTypeFor(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestHTTPEngineSkipsBlockedPages(t *testing.T) {
+	requested := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested++
+		fmt.Fprint(w, "<html><body>page</body></html>")
+	}))
+	defer server.Close()
+
+	interceptor, err := NewInterceptor(writeInterceptRules(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher, err := NewResourceFetcher(FetchHTTP, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture := &NetworkCapture{
+		Interceptor: interceptor,
+		Fetcher:     fetcher,
+		Traffic:     NewTrafficRecorder(),
+		Inventory:   NewEndpointInventory("127.0.0.1"),
+		PageTimeout: 5 * time.Second,
+	}
+	engine := &HTTPEngine{capture: capture}
+	ctx, err := engine.Start(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := engine.Load(ctx, server.URL+"/"); err != nil {
+		t.Fatalf("Load() = %v", err)
+	}
+	if _, err := engine.Load(ctx, server.URL+"/ads/landing.html"); !errors.Is(err, errBlocked) {
+		t.Fatalf("Load() of a blocked page = %v, want errBlocked", err)
+	}
+	if requested != 1 {
+		t.Errorf("server got %d requests, want the blocked page never requested", requested)
+	}
+	frames, err := engine.Frames(ctx)
+	if err != nil || frames[0].HTML != "" {
+		t.Errorf("Frames() after a blocked page = %q, %v, want no HTML", frames[0].HTML, err)
+	}
+}
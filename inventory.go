@@ -0,0 +1,305 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// parameterOrder sorts parameters by location in the report
+var parameterOrder = map[string]int{ParamPath: 0, ParamQuery: 1, ParamBody: 2}
+
+// maxParameterExamples limits the example values kept per parameter
+const maxParameterExamples = 5
+
+// Parameter locations
+const (
+	ParamPath  = "path"
+	ParamQuery = "query"
+	ParamBody  = "body"
+)
+
+// Endpoint sources
+const (
+	SourceLink     = "link"
+	SourceResource = "resource"
+	SourceForm     = "form"
+	SourceNetwork  = "network"
+
+	SourceWebSocket   = "websocket"
+	SourceEventSource = "eventsource"
+)
+
+// EndpointParameter represents a parameter name seen on an endpoint
+type EndpointParameter struct {
+	Name     string   `json:"name"`
+	Location string   `json:"location"`
+	Examples []string `json:"examples,omitempty"`
+}
+
+// Endpoint represents a method, host and path template seen during the crawl
+type Endpoint struct {
+	Method     string               `json:"method"`
+	Host       string               `json:"host"`
+	Path       string               `json:"path"`
+	Parameters []*EndpointParameter `json:"parameters,omitempty"`
+	Sources    []string             `json:"sources"`
+	Referrers  []string             `json:"referrers,omitempty"`
+}
+
+// EndpointInventory aggregates every in-scope URL discovered during the crawl.
+// It is safe for concurrent use.
+type EndpointInventory struct {
+	targetHost string
+	mu         sync.Mutex
+	endpoints  map[string]*Endpoint
+}
+
+// NewEndpointInventory creates an inventory scoped to the target host and its subdomains
+func NewEndpointInventory(targetHost string) *EndpointInventory {
+	return &EndpointInventory{
+		targetHost: targetHost,
+		endpoints:  make(map[string]*Endpoint),
+	}
+}
+
+// AddURL records a URL and its query parameters
+func (inv *EndpointInventory) AddURL(method, rawURL, referrer, source string) {
+	inv.add(method, rawURL, referrer, source, nil)
+}
+
+// AddForm records a form submission endpoint and its fields
+func (inv *EndpointInventory) AddForm(form FormInfo, referrer string) {
+	fields := url.Values{}
+	for _, field := range form.Fields {
+		fields.Add(field.Name, field.Value)
+	}
+
+	if form.Method == http.MethodGet {
+		// GET forms submit their fields in the query string
+		parsedURL, err := url.Parse(form.Action)
+		if err != nil {
+			return
+		}
+		query := parsedURL.Query()
+		for name, values := range fields {
+			query[name] = append(query[name], values...)
+		}
+		parsedURL.RawQuery = query.Encode()
+		inv.add(form.Method, parsedURL.String(), referrer, SourceForm, nil)
+		return
+	}
+	inv.add(form.Method, form.Action, referrer, SourceForm, fields)
+}
+
+// AddRequest records a request observed on the network, including its body parameters
+func (inv *EndpointInventory) AddRequest(method, rawURL, referrer, contentType, body string) {
+	inv.add(method, rawURL, referrer, SourceNetwork, parseBodyParams(contentType, body))
+}
+
+func (inv *EndpointInventory) add(method, rawURL, referrer, source string, bodyParams url.Values) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return
+	}
+	switch parsedURL.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return
+	}
+	if !isSameOrSubdomain(inv.targetHost, parsedURL.Host) {
+		return
+	}
+
+	if method == "" {
+		method = http.MethodGet
+	}
+	method = strings.ToUpper(method)
+	host := strings.ToLower(parsedURL.Host)
+	path, pathParams := templatePath(parsedURL.EscapedPath())
+	if path == "" {
+		path = "/"
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	key := method + " " + host + path
+	endpoint, ok := inv.endpoints[key]
+	if !ok {
+		endpoint = &Endpoint{Method: method, Host: host, Path: path}
+		inv.endpoints[key] = endpoint
+	}
+
+	endpoint.Sources = appendUnique(endpoint.Sources, source)
+	if referrer != "" && referrer != rawURL {
+		endpoint.Referrers = appendUnique(endpoint.Referrers, referrer)
+	}
+	for _, segment := range pathParams {
+		endpoint.addParameter(segment.Name, ParamPath, []string{segment.Value})
+	}
+	for name, values := range parsedURL.Query() {
+		endpoint.addParameter(name, ParamQuery, values)
+	}
+	for name, values := range bodyParams {
+		endpoint.addParameter(name, ParamBody, values)
+	}
+}
+
+func (e *Endpoint) addParameter(name, location string, values []string) {
+	var param *EndpointParameter
+	for _, existing := range e.Parameters {
+		if existing.Name == name && existing.Location == location {
+			param = existing
+			break
+		}
+	}
+	if param == nil {
+		param = &EndpointParameter{Name: name, Location: location}
+		e.Parameters = append(e.Parameters, param)
+	}
+	for _, value := range values {
+		if value == "" || len(param.Examples) >= maxParameterExamples {
+			continue
+		}
+		param.Examples = appendUnique(param.Examples, value)
+	}
+}
+
+// Endpoints returns the inventory sorted by host, path and method
+func (inv *EndpointInventory) Endpoints() []*Endpoint {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	endpoints := make([]*Endpoint, 0, len(inv.endpoints))
+	for _, endpoint := range inv.endpoints {
+		sort.Slice(endpoint.Parameters, func(i, j int) bool {
+			if endpoint.Parameters[i].Location != endpoint.Parameters[j].Location {
+				return parameterOrder[endpoint.Parameters[i].Location] < parameterOrder[endpoint.Parameters[j].Location]
+			}
+			return endpoint.Parameters[i].Name < endpoint.Parameters[j].Name
+		})
+		sort.Strings(endpoint.Sources)
+		sort.Strings(endpoint.Referrers)
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Host != endpoints[j].Host {
+			return endpoints[i].Host < endpoints[j].Host
+		}
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return endpoints
+}
+
+// SaveEndpoints writes the endpoint inventory to endpoints.json and endpoints.md
+func (nc *NetworkCapture) SaveEndpoints() {
+	endpoints := nc.Inventory.Endpoints()
+	if len(endpoints) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(endpoints, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode endpoint inventory", "error", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(nc.OutputDir, "endpoints.json"), data, 0644); err != nil {
+		slog.Error("Failed to write endpoint inventory", "error", err)
+		return
+	}
+
+	if err := os.WriteFile(filepath.Join(nc.OutputDir, "endpoints.md"), []byte(formatEndpointsMarkdown(endpoints)), 0644); err != nil {
+		slog.Error("Failed to write endpoint report", "error", err)
+		return
+	}
+	slog.Info("Saved endpoint inventory", "endpoints", len(endpoints))
+}
+
+// formatEndpointsMarkdown renders the inventory as a Markdown table
+func formatEndpointsMarkdown(endpoints []*Endpoint) string {
+	var b strings.Builder
+	b.WriteString("# Endpoint Inventory\n\n")
+	b.WriteString("| Method | Host | Path | Parameters | Sources | Referenced from |\n")
+	b.WriteString("|--------|------|------|------------|---------|-----------------|\n")
+	for _, endpoint := range endpoints {
+		var params []string
+		for _, param := range endpoint.Parameters {
+			entry := fmt.Sprintf("`%s` (%s)", param.Name, param.Location)
+			if len(param.Examples) > 0 {
+				entry += " e.g. `" + param.Examples[0] + "`"
+			}
+			params = append(params, entry)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+			endpoint.Method,
+			escapeMarkdownCell(endpoint.Host),
+			escapeMarkdownCell(endpoint.Path),
+			escapeMarkdownCell(strings.Join(params, "<br>")),
+			strings.Join(endpoint.Sources, ", "),
+			escapeMarkdownCell(strings.Join(endpoint.Referrers, "<br>")))
+	}
+	return b.String()
+}
+
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// parseBodyParams extracts parameter names and values from a request body
+func parseBodyParams(contentType, body string) url.Values {
+	if body == "" {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case strings.Contains(mediaType, "json"):
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(body), &fields); err != nil {
+			return nil
+		}
+		params := url.Values{}
+		for name, value := range fields {
+			switch v := value.(type) {
+			case string:
+				params.Add(name, v)
+			case nil:
+				params.Add(name, "")
+			default:
+				encoded, _ := json.Marshal(v)
+				params.Add(name, string(encoded))
+			}
+		}
+		return params
+	case mediaType == "application/x-www-form-urlencoded":
+		params, err := url.ParseQuery(body)
+		if err != nil {
+			return nil
+		}
+		return params
+	}
+	return nil
+}
+
+// appendUnique appends value to values unless it is already present
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
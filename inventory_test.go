@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParseBodyParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        url.Values
+	}{
+		{"form", "application/x-www-form-urlencoded", "user=alice&role=admin&role=dev",
+			url.Values{"user": {"alice"}, "role": {"admin", "dev"}}},
+		{"form with charset", "application/x-www-form-urlencoded; charset=UTF-8", "q=a%20b",
+			url.Values{"q": {"a b"}}},
+		{"json", "application/json", `{"name":"bob","age":42,"tags":["a"],"note":null}`,
+			url.Values{"name": {"bob"}, "age": {"42"}, "tags": {`["a"]`}, "note": {""}}},
+		{"json suffix", "application/vnd.api+json", `{"id":"7"}`, url.Values{"id": {"7"}}},
+		{"json array", "application/json", `[1,2]`, nil},
+		{"invalid json", "application/json", `{"name":`, nil},
+		{"plain text", "text/plain", "user=alice", nil},
+		{"empty", "application/json", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseBodyParams(tt.contentType, tt.body); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBodyParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndpointInventoryMergesObservations(t *testing.T) {
+	inv := NewEndpointInventory("example.com")
+	inv.AddURL("get", "https://example.com/users/1?tab=posts", "https://example.com/", SourceLink)
+	inv.AddURL("GET", "https://example.com/users/2?tab=likes&sort=new", "https://example.com/team", SourceLink)
+	inv.AddRequest("POST", "https://api.example.com/v1/login", "https://example.com/", "application/json", `{"user":"a"}`)
+	inv.AddForm(FormInfo{
+		Action: "https://api.example.com/v1/login",
+		Method: "POST",
+		Fields: []FormField{{Name: "user", Value: "b"}, {Name: "password"}},
+	}, "https://example.com/login")
+	inv.AddForm(FormInfo{
+		Action: "https://example.com/search?lang=en",
+		Method: "GET",
+		Fields: []FormField{{Name: "q"}},
+	}, "https://example.com/")
+	inv.AddURL("GET", "https://cdn.other.net/lib.js", "https://example.com/", SourceResource)
+
+	endpoints := inv.Endpoints()
+	if len(endpoints) != 3 {
+		t.Fatalf("inventory has %d endpoints, want 3: %+v", len(endpoints), endpoints)
+	}
+
+	login := endpoints[0]
+	if login.Method != "POST" || login.Host != "api.example.com" || login.Path != "/v1/login" {
+		t.Fatalf("first endpoint = %s %s%s", login.Method, login.Host, login.Path)
+	}
+	if !reflect.DeepEqual(login.Sources, []string{SourceForm, SourceNetwork}) {
+		t.Errorf("login sources = %v", login.Sources)
+	}
+	if !reflect.DeepEqual(login.Referrers, []string{"https://example.com/", "https://example.com/login"}) {
+		t.Errorf("login referrers = %v", login.Referrers)
+	}
+	wantLogin := []EndpointParameter{
+		{Name: "password", Location: ParamBody},
+		{Name: "user", Location: ParamBody, Examples: []string{"a", "b"}},
+	}
+	checkParameters(t, login, wantLogin)
+
+	search := endpoints[1]
+	if search.Path != "/search" {
+		t.Fatalf("second endpoint path = %q", search.Path)
+	}
+	checkParameters(t, search, []EndpointParameter{
+		{Name: "lang", Location: ParamQuery, Examples: []string{"en"}},
+		{Name: "q", Location: ParamQuery},
+	})
+
+	users := endpoints[2]
+	if users.Path != "/users/{id}" || users.Method != "GET" {
+		t.Fatalf("third endpoint = %s %s", users.Method, users.Path)
+	}
+	checkParameters(t, users, []EndpointParameter{
+		{Name: "id", Location: ParamPath, Examples: []string{"1", "2"}},
+		{Name: "sort", Location: ParamQuery, Examples: []string{"new"}},
+		{Name: "tab", Location: ParamQuery, Examples: []string{"posts", "likes"}},
+	})
+}
+
+func TestEndpointParameterExamplesAreCapped(t *testing.T) {
+	inv := NewEndpointInventory("example.com")
+	for _, page := range []string{"1", "2", "2", "3", "4", "5", "6", "7"} {
+		inv.AddURL("GET", "https://example.com/list?page="+page, "", SourceLink)
+	}
+	param := inv.Endpoints()[0].Parameters[0]
+	if want := []string{"1", "2", "3", "4", "5"}; !reflect.DeepEqual(param.Examples, want) {
+		t.Errorf("examples = %v, want the first %d distinct values", param.Examples, maxParameterExamples)
+	}
+}
+
+// checkParameters compares an endpoint's parameters, in report order
+func checkParameters(t *testing.T, endpoint *Endpoint, want []EndpointParameter) {
+	t.Helper()
+	var got []EndpointParameter
+	for _, param := range endpoint.Parameters {
+		got = append(got, *param)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s %s parameters =\n%+v\nwant\n%+v", endpoint.Method, endpoint.Path, got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Log output formats
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// newLogger creates the crawl logger. Logs are written to stderr, or to
+// logFile when one is given, so stdout only carries the captured URLs.
+// The returned closer releases the log file and is nil for stderr.
+func newLogger(verbose, quiet bool, format, logFile string, stderr io.Writer) (*slog.Logger, io.Closer, error) {
+	if verbose && quiet {
+		return nil, nil, fmt.Errorf("-v and -q cannot be used together")
+	}
+
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	} else if quiet {
+		level = slog.LevelWarn
+	}
+
+	out := stderr
+	var closer io.Closer
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		out = file
+		closer = file
+	}
+
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format {
+	case LogFormatText:
+		handler = slog.NewTextHandler(out, options)
+	case LogFormatJSON:
+		handler = slog.NewJSONHandler(out, options)
+	default:
+		if closer != nil {
+			closer.Close()
+		}
+		return nil, nil, fmt.Errorf("unknown log format %q (expected text or json)", format)
+	}
+	return slog.New(handler), closer, nil
+}
+
+// chromedpLogf adapts a log level to chromedp's printf-style logger options
+func chromedpLogf(level slog.Level) func(string, ...interface{}) {
+	return func(format string, args ...interface{}) {
+		slog.Log(context.Background(), level, fmt.Sprintf(format, args...), "component", "chromedp")
+	}
+}
+
+// progressEnabled reports whether human-readable progress output is wanted
+func progressEnabled() bool {
+	return slog.Default().Enabled(context.Background(), slog.LevelInfo)
+}
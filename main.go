@@ -2,9 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
@@ -18,9 +20,24 @@ import (
 )
 
 type ResponseData struct {
-	URL      string `json:"url"`
-	Body     string `json:"body"`
-	MimeType string `json:"mime_type"`
+	URL              string `json:"url"`
+	Body             []byte `json:"body"`
+	MimeType         string `json:"mime_type"`
+	DeclaredMimeType string `json:"declared_mime_type,omitempty"`
+	DetectedMimeType string `json:"detected_mime_type,omitempty"`
+}
+
+// NewResponseData creates a response, detecting its MIME type from the
+// declared Content-Type and the body
+func NewResponseData(urlStr string, body []byte, declaredType string) ResponseData {
+	detected := detectMimeType(body, urlStr)
+	return ResponseData{
+		URL:              urlStr,
+		Body:             body,
+		MimeType:         resolveMimeType(declaredType, detected),
+		DeclaredMimeType: baseMimeType(declaredType),
+		DetectedMimeType: detected,
+	}
 }
 
 // AbsoluteURL resolves a relative path against the response URL
@@ -91,65 +108,256 @@ func NewRequestFromResponse(path, source, tag, attribute string, resp *ResponseD
 }
 
 type NetworkCapture struct {
-	TargetHost    string
-	Responses     []ResponseData
-	OutputDir     string
-	CustomHeaders map[string]string
-	VisitedURLs   map[string]bool
-	MaxDepth      int
+	TargetHost      string
+	Responses       []ResponseData
+	Failures        []FailedRequest
+	Inventory       *EndpointInventory
+	Traffic         *TrafficRecorder
+	Streams         *StreamRecorder
+	APICalls        *APICallRecorder
+	Templates       *PathTemplates
+	Secrets         *SecretScanner
+	Mirror          bool
+	Screenshots     bool
+	PDF             bool
+	Captures        []PageCapture
+	Graph           *SiteGraph
+	GraphFormats    []string
+	Sources         []SourceFile
+	Stats           *CrawlStats
+	Metrics         *Metrics
+	Resolver        *URLResolver
+	Fetcher         *ResourceFetcher
+	Interceptor     *Interceptor
+	OutputDir       string
+	CustomHeaders   []CustomHeader
+	VisitedURLs     map[string]bool
+	MaxDepth        int
+	PageTimeout     time.Duration
+	ResourceTimeout time.Duration
+	ScriptTimeout   time.Duration
+	Output          io.Writer
+
+	// Local paths assigned to captured URLs, and the URL owning each path
+	mirrorPaths  map[string]string
+	mirrorOwners map[string]string
 }
 
 // LinkInfo represents a link with metadata
 type LinkInfo struct {
-	URL       string
-	Tag       string
-	Attribute string
-	Text      string
+	URL        string
+	Tag        string
+	Attribute  string
+	Text       string
+	Resolution string
+	Frame      string
 }
 
 func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if err != errUsage {
+			slog.Error("Crawl failed", "error", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// errUsage reports that the usage message was shown instead of crawling
+var errUsage = errors.New("usage")
+
+// run parses the command line and crawls the target, writing captured URLs
+// to stdout and logs to stderr
+func run(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("crawler", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
 	// Define URL flag
 	var targetURL string
-	flag.StringVar(&targetURL, "u", "", "Target URL to crawl")
+	fs.StringVar(&targetURL, "u", "", "Target URL to crawl")
 
 	// Define custom headers flag
-	var headers []string
-	flag.Var((*stringSlice)(&headers), "H", "Custom header (can be used multiple times, e.g., -H 'User-Agent: MyBot' -H 'Accept: application/json')")
+	headers := &headerFlags{scopes: make(map[int][]string)}
+	fs.Var(headers, "H", "Custom header (can be used multiple times, e.g., -H 'User-Agent: MyBot' -H 'Accept: application/json')")
+	fs.Var(headerScopeFlag{headers}, "header-scope", "Comma-separated hosts the preceding -H header is sent to, with their subdomains, or * for every host (default: the target host)")
 
 	// Define crawl depth flag
 	var crawlDepth int
-	flag.IntVar(&crawlDepth, "depth", 5, "Maximum crawl depth (default: 5)")
+	fs.IntVar(&crawlDepth, "depth", 5, "Maximum crawl depth (default: 5)")
 
 	// Define retry flag
 	var maxRetries int
-	flag.IntVar(&maxRetries, "retries", 3, "Maximum number of retry attempts for failed connections (default: 3)")
+	fs.IntVar(&maxRetries, "retries", 3, "Maximum number of retry attempts for failed connections (default: 3)")
+
+	// Define timeout flags
+	var crawlTimeout, pageTimeout, resourceTimeout, scriptTimeout time.Duration
+	fs.DurationVar(&crawlTimeout, "timeout", defaultCrawlTimeout, "Maximum duration of the whole crawl (default: 5m)")
+	fs.DurationVar(&pageTimeout, "page-timeout", defaultPageTimeout, "Maximum time to load a single page (default: 30s)")
+	fs.DurationVar(&resourceTimeout, "resource-timeout", defaultResourceTimeout, "Maximum time to fetch a single resource (default: 30s)")
+	fs.DurationVar(&scriptTimeout, "script-timeout", defaultScriptTimeout, "Maximum time for a script evaluation on a page (default: 10s)")
+
+	// Define crawl ordering flags
+	var strategy string
+	fs.StringVar(&strategy, "strategy", StrategyBFS, "Crawl ordering strategy: bfs, dfs, priority or random (default: bfs)")
+
+	var maxPages int
+	fs.IntVar(&maxPages, "max-pages", 0, "Maximum number of pages to crawl, 0 for no limit (default: 0)")
+
+	// Define URL resolution flag
+	var resolvePolicy string
+	fs.StringVar(&resolvePolicy, "resolve", ResolveStandard, "Relative URL resolution: standard, fallback (also guess root-relative) or probe (keep guesses that exist) (default: standard)")
+
+	// Define engine flag
+	var engineName string
+	fs.StringVar(&engineName, "engine", EngineChrome, "Crawl engine: chrome (renders pages and runs scripts) or http (plain HTTP client, no browser needed) (default: chrome)")
+
+	// Define resource fetching flags
+	var fetchMode string
+	fs.StringVar(&fetchMode, "fetch", FetchHybrid, "How resources are fetched: browser, http or hybrid (HTTP for static resources, browser for HTML) (default: hybrid)")
+
+	var fetchRules []string
+	fs.Var((*stringSlice)(&fetchRules), "fetch-rule", "Fetch mode for matching resources as PATTERN=MODE, where PATTERN is a MIME type or url:REGEX (can be used multiple times, e.g., -fetch-rule 'image/*=http' -fetch-rule 'url:/api/=browser')")
+
+	var interceptFile string
+	fs.StringVar(&interceptFile, "intercept", "", "JSON file of request interception rules: blocked resource types and URLs, per-host headers and mocked responses")
+
+	var proxy string
+	fs.StringVar(&proxy, "proxy", "", "Proxy for the browser and HTTP fetches, e.g. http://127.0.0.1:8080")
+
+	// Define path template flag
+	var templateLimit int
+	fs.IntVar(&templateLimit, "template-limit", defaultTemplateLimit, "Maximum URLs crawled per path template such as /users/{id}, 0 for no limit (default: 10)")
+
+	// Define secret scanning flags
+	var scanSecrets bool
+	fs.BoolVar(&scanSecrets, "scan-secrets", false, "Scan captured bodies for API keys, tokens and other sensitive data")
+
+	var secretRulesFile string
+	fs.StringVar(&secretRulesFile, "secret-rules", "", "JSON file of additional secret rules, implies -scan-secrets")
+
+	// Define output mode flags
+	var mirror bool
+	fs.BoolVar(&mirror, "mirror", false, "Save responses under host/path directories with links rewritten for offline browsing")
+
+	var screenshots, pdf bool
+	fs.BoolVar(&screenshots, "screenshots", false, "Save a full-page PNG screenshot of every crawled page, next to the page with -mirror and under captures/ otherwise")
+	fs.BoolVar(&pdf, "pdf", false, "Save a PDF rendering of every crawled page, next to the page with -mirror and under captures/ otherwise")
+
+	var viewport string
+	fs.StringVar(&viewport, "viewport", fmt.Sprintf("%dx%d", defaultViewportWidth, defaultViewportHeight), "Browser viewport size as WIDTHxHEIGHT (default: 1280x800)")
+
+	var graphFormats string
+	fs.StringVar(&graphFormats, "graph", "", "Export the link graph in these comma-separated formats: dot, graphml, json")
+
+	var warcFile string
+	fs.StringVar(&warcFile, "warc", "", "Also write every fetched page and resource to a WARC file, e.g. crawl.warc.gz")
+
+	var streamFrames int
+	fs.IntVar(&streamFrames, "stream-frames", defaultStreamFrames, "Maximum WebSocket frames and server-sent events kept per page, 0 for no limit (default: 100)")
+
+	// Define logging flags
+	var verbose, quiet bool
+	fs.BoolVar(&verbose, "v", false, "Verbose logging, including debug messages")
+	fs.BoolVar(&quiet, "q", false, "Quiet logging, only warnings and errors")
+
+	var logFormat, logFile string
+	fs.StringVar(&logFormat, "log-format", LogFormatText, "Log format: text or json (default: text)")
+	fs.StringVar(&logFile, "log-file", "", "Write logs to this file instead of stderr")
+
+	// Define monitoring flags
+	var metricsAddr string
+	fs.StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics at /metrics on this address, e.g. :9090")
 
 	// Parse flags
-	flag.Parse()
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	// Show a live status line when stderr is a terminal
+	metrics := NewMetrics()
+	var display *ProgressDisplay
+	logOutput := stderr
+	if f, ok := stderr.(*os.File); ok && !quiet && isTerminal(f) {
+		display = NewProgressDisplay(stderr, metrics)
+		logOutput = display
+	}
+
+	logger, logCloser, err := newLogger(verbose, quiet, logFormat, logFile, logOutput)
+	if err != nil {
+		return err
+	}
+	if logCloser != nil {
+		defer logCloser.Close()
+	}
+	// Put the previous logger back before the log file is closed
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logger)
 
 	// Get remaining arguments after flags
-	args := flag.Args()
+	args = fs.Args()
 
 	// Check if URL is provided via flag or argument
 	if targetURL == "" {
 		if len(args) < 1 {
-			fmt.Println("Usage: go run main.go [flags] <url> [output_directory]")
-			fmt.Println("       ./crawler [flags] <url> [output_directory]")
-			fmt.Println("       ./crawler -u <url> [flags] [output_directory]")
-			fmt.Println("Flags:")
-			fmt.Println("  -u url              Target URL to crawl")
-			fmt.Println("  -H header           Custom header (can be used multiple times)")
-			fmt.Println("  -depth N            Maximum crawl depth (default: 5)")
-			fmt.Println("  -retries N          Maximum retry attempts for failed connections (default: 3)")
-			fmt.Println("")
-			fmt.Println("Examples:")
-			fmt.Println("  go run main.go [url]")
-			fmt.Println("  ./crawler [url]")
-			fmt.Println("  ./crawler -u [url]")
-			fmt.Println("  ./crawler -u [url] -depth 3 ./output")
-			fmt.Println("  ./crawler -H 'User-Agent: MyBot' -depth 2 [url]")
-			fmt.Println("  ./crawler -retries 5 [url]")
-			os.Exit(1)
+			fmt.Fprintln(stdout, "Usage: go run . [flags] <url> [output_directory]")
+			fmt.Fprintln(stdout, "       ./crawler [flags] <url> [output_directory]")
+			fmt.Fprintln(stdout, "       ./crawler -u <url> [flags] [output_directory]")
+			fmt.Fprintln(stdout, "Flags:")
+			fmt.Fprintln(stdout, "  -u url              Target URL to crawl")
+			fmt.Fprintln(stdout, "  -H header           Custom header, sent to the target host (can be used multiple times)")
+			fmt.Fprintln(stdout, "  -header-scope H     Hosts the preceding -H header is sent to, comma-separated, or * for all")
+			fmt.Fprintln(stdout, "  -depth N            Maximum crawl depth (default: 5)")
+			fmt.Fprintln(stdout, "  -retries N          Maximum retry attempts for failed connections (default: 3)")
+			fmt.Fprintln(stdout, "  -timeout D          Maximum duration of the whole crawl (default: 5m)")
+			fmt.Fprintln(stdout, "  -page-timeout D     Maximum time to load a single page (default: 30s)")
+			fmt.Fprintln(stdout, "  -resource-timeout D Maximum time to fetch a single resource (default: 30s)")
+			fmt.Fprintln(stdout, "  -script-timeout D   Maximum time for a script evaluation (default: 10s)")
+			fmt.Fprintln(stdout, "  -strategy S         Crawl ordering: bfs, dfs, priority or random (default: bfs)")
+			fmt.Fprintln(stdout, "  -max-pages N        Maximum number of pages to crawl, 0 for no limit (default: 0)")
+			fmt.Fprintln(stdout, "  -template-limit N   Maximum URLs crawled per path template, 0 for no limit (default: 10)")
+			fmt.Fprintln(stdout, "  -resolve P          Relative URL resolution: standard, fallback or probe (default: standard)")
+			fmt.Fprintln(stdout, "  -engine E           Crawl engine: chrome or http, which needs no browser (default: chrome)")
+			fmt.Fprintln(stdout, "  -fetch M            How resources are fetched: browser, http or hybrid (default: hybrid)")
+			fmt.Fprintln(stdout, "  -fetch-rule R       Fetch mode for matching resources as PATTERN=MODE (can be used multiple times)")
+			fmt.Fprintln(stdout, "  -intercept file     JSON file of rules to block, rewrite headers of and mock requests")
+			fmt.Fprintln(stdout, "  -proxy url          Proxy for the browser and HTTP fetches")
+			fmt.Fprintln(stdout, "  -scan-secrets       Scan captured bodies for API keys, tokens and other sensitive data")
+			fmt.Fprintln(stdout, "  -secret-rules file  JSON file of additional secret rules, implies -scan-secrets")
+			fmt.Fprintln(stdout, "  -mirror             Save responses as a browsable offline mirror of the site")
+			fmt.Fprintln(stdout, "  -screenshots        Save a full-page PNG screenshot of every crawled page")
+			fmt.Fprintln(stdout, "  -pdf                Save a PDF rendering of every crawled page")
+			fmt.Fprintln(stdout, "                      (next to the page with -mirror, under captures/ otherwise)")
+			fmt.Fprintln(stdout, "  -viewport WxH       Browser viewport size (default: 1280x800)")
+			fmt.Fprintln(stdout, "  -graph formats      Export the link graph as dot, graphml and/or json")
+			fmt.Fprintln(stdout, "  -warc file          Also write every fetched page and resource to a WARC file")
+			fmt.Fprintln(stdout, "  -stream-frames N    Maximum WebSocket frames and server-sent events kept per page (default: 100)")
+			fmt.Fprintln(stdout, "  -v                  Verbose logging, including debug messages")
+			fmt.Fprintln(stdout, "  -q                  Quiet logging, only warnings and errors")
+			fmt.Fprintln(stdout, "  -log-format F       Log format: text or json (default: text)")
+			fmt.Fprintln(stdout, "  -log-file file      Write logs to this file instead of stderr")
+			fmt.Fprintln(stdout, "  -metrics-addr addr  Serve Prometheus metrics at /metrics on this address")
+			fmt.Fprintln(stdout, "")
+			fmt.Fprintln(stdout, "Examples:")
+			fmt.Fprintln(stdout, "  go run . [url]")
+			fmt.Fprintln(stdout, "  ./crawler [url]")
+			fmt.Fprintln(stdout, "  ./crawler -u [url]")
+			fmt.Fprintln(stdout, "  ./crawler -u [url] -depth 3 ./output")
+			fmt.Fprintln(stdout, "  ./crawler -H 'User-Agent: MyBot' -depth 2 [url]")
+			fmt.Fprintln(stdout, "  ./crawler -H 'Authorization: Bearer x' -header-scope api.example.com [url]")
+			fmt.Fprintln(stdout, "  ./crawler -retries 5 [url]")
+			fmt.Fprintln(stdout, "  ./crawler -page-timeout 10s -timeout 30m [url]")
+			fmt.Fprintln(stdout, "  ./crawler -strategy priority -max-pages 100 [url]")
+			fmt.Fprintln(stdout, "  ./crawler -resolve probe [url]")
+			fmt.Fprintln(stdout, "  ./crawler -engine http -depth 3 [url]")
+			fmt.Fprintln(stdout, "  ./crawler -intercept rules.json [url]")
+			fmt.Fprintln(stdout, "  ./crawler -fetch-rule 'url:/api/=browser' -proxy http://127.0.0.1:8080 [url]")
+			fmt.Fprintln(stdout, "  ./crawler -scan-secrets -secret-rules rules.json [url]")
+			fmt.Fprintln(stdout, "  ./crawler -mirror -depth 2 [url] ./mirror")
+			fmt.Fprintln(stdout, "  ./crawler -warc crawl.warc.gz [url]")
+			fmt.Fprintln(stdout, "  ./crawler -screenshots -viewport 390x844 [url]")
+			fmt.Fprintln(stdout, "  ./crawler -graph dot,json [url]")
+			fmt.Fprintln(stdout, "  ./crawler -q -log-format json -log-file crawl.log [url] > urls.txt")
+			fmt.Fprintln(stdout, "  ./crawler -metrics-addr :9090 -timeout 2h [url]")
+			return errUsage
 		}
 		targetURL = args[0]
 		args = args[1:] // Remove URL from args
@@ -160,135 +368,234 @@ func main() {
 		outputDir = args[0]
 		// Check if the output directory argument looks like a flag
 		if strings.HasPrefix(outputDir, "-") {
-			fmt.Printf("Error: '%s' looks like a flag. Did you mean to specify an output directory?\n", outputDir)
-			fmt.Println("Usage: go run main.go [flags] <url> [output_directory]")
-			fmt.Println("       ./crawler [flags] <url> [output_directory]")
-			fmt.Println("       ./crawler -u <url> [flags] [output_directory]")
-			os.Exit(1)
-		}
-	}
-
-	// Parse custom headers
-	customHeaders := make(map[string]string)
-	for _, header := range headers {
-		parts := strings.SplitN(header, ":", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			customHeaders[key] = value
-		} else {
-			log.Printf("Warning: Invalid header format '%s', expected 'Key: Value'", header)
+			fmt.Fprintf(stderr, "Error: '%s' looks like a flag. Did you mean to specify an output directory?\n", outputDir)
+			fmt.Fprintln(stderr, "Usage: go run . [flags] <url> [output_directory]")
+			fmt.Fprintln(stderr, "       ./crawler [flags] <url> [output_directory]")
+			fmt.Fprintln(stderr, "       ./crawler -u <url> [flags] [output_directory]")
+			return errUsage
 		}
 	}
 
-	fmt.Printf("Debug: Parsed arguments - URL: %s, OutputDir: %s\n", targetURL, outputDir)
-	fmt.Printf("Debug: Custom headers: %v\n", customHeaders)
-
 	// Parse the target URL to extract host
 	parsedURL, err := url.Parse(targetURL)
 	if err != nil {
-		log.Fatal("Invalid URL:", err)
+		return fmt.Errorf("invalid URL %s: %w", targetURL, err)
+	}
+
+	// Parse custom headers, sent to the target host unless scoped otherwise
+	customHeaders := parseCustomHeaders(headers, normalizeHost(parsedURL.Host))
+
+	slog.Debug("Parsed arguments", "url", targetURL, "output_dir", outputDir, "headers", len(customHeaders))
+
+	crawlQueue, err := NewFrontier(strategy)
+	if err != nil {
+		return err
+	}
+
+	var secrets *SecretScanner
+	if scanSecrets || secretRulesFile != "" {
+		secrets, err = NewSecretScanner(secretRulesFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	fetcher, err := NewResourceFetcher(fetchMode, fetchRules, proxy)
+	if err != nil {
+		return err
+	}
+
+	resolver, err := NewURLResolver(resolvePolicy, customHeaders, fetcher)
+	if err != nil {
+		return err
+	}
+
+	interceptor, err := NewInterceptor(interceptFile, customHeaders)
+	if err != nil {
+		return err
+	}
+
+	viewportWidth, viewportHeight, err := parseViewport(viewport)
+	if err != nil {
+		return err
+	}
+
+	parsedGraphFormats, err := parseGraphFormats(graphFormats)
+	if err != nil {
+		return err
 	}
 
 	// Create output directory
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		log.Fatal("Failed to create output directory:", err)
+		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
 	capture := &NetworkCapture{
 		TargetHost:    normalizeHost(parsedURL.Host),
 		Responses:     make([]ResponseData, 0),
+		Inventory:     NewEndpointInventory(normalizeHost(parsedURL.Host)),
+		Traffic:       NewTrafficRecorder(),
+		Streams:       NewStreamRecorder(streamFrames),
+		APICalls:      NewAPICallRecorder(),
+		Templates:     NewPathTemplates(templateLimit),
+		Secrets:       secrets,
+		Mirror:        mirror,
+		Screenshots:   screenshots,
+		PDF:           pdf,
+		Graph:         NewSiteGraph(),
+		GraphFormats:  parsedGraphFormats,
+		Stats:         NewCrawlStats(),
+		Metrics:       metrics,
+		Resolver:      resolver,
+		Fetcher:       fetcher,
+		Interceptor:   interceptor,
 		OutputDir:     outputDir,
 		CustomHeaders: customHeaders,
 		VisitedURLs:   make(map[string]bool),
 		MaxDepth:      crawlDepth, // Use the parsed depth
+
+		PageTimeout:     pageTimeout,
+		ResourceTimeout: resourceTimeout,
+		ScriptTimeout:   scriptTimeout,
+		Output:          stdout,
 	}
 
-	fmt.Printf("Starting crawler for: %s\n", targetURL)
-	fmt.Printf("Output directory: %s\n", outputDir)
-	if len(customHeaders) > 0 {
-		fmt.Printf("Custom headers: %d configured\n", len(customHeaders))
+	engine, err := NewEngine(engineName, capture, proxy, viewportWidth, viewportHeight)
+	if err != nil {
+		return err
 	}
 
-	// Create Chrome context
-	ctx, cancel := chromedp.NewContext(
-		context.Background(),
-		chromedp.WithLogf(log.Printf),
-	)
-	defer cancel()
+	// Write fetched exchanges to a WARC file if requested
+	var warcWriter *WARCWriter
+	if warcFile != "" {
+		warcWriter, err = NewWARCWriter(warcFile)
+		if err != nil {
+			return err
+		}
+		capture.Traffic.Handle(func(ex *Exchange) {
+			if err := warcWriter.WriteExchange(ex); err != nil {
+				slog.Error("Failed to write WARC record", "url", ex.URL, "error", err)
+			}
+		})
+	}
 
-	// Set timeout
-	ctx, cancel = context.WithTimeout(ctx, 300*time.Second) // Increased to 5 minutes
-	defer cancel()
+	slog.Info("Starting crawler", "url", targetURL, "output_dir", outputDir, "engine", engineName, "headers", len(customHeaders))
 
-	// Enable network events
-	if err := chromedp.Run(ctx, network.Enable()); err != nil {
-		log.Fatal("Failed to enable network:", err)
+	if metricsAddr != "" {
+		metricsServer, err := serveMetrics(metricsAddr, metrics)
+		if err != nil {
+			return err
+		}
+		defer metricsServer.Close()
 	}
 
-	// Set custom headers if provided
-	if len(customHeaders) > 0 {
-		headers := make(map[string]interface{})
-		for key, value := range customHeaders {
-			headers[key] = value
-		}
-		if err := chromedp.Run(ctx, network.SetExtraHTTPHeaders(headers)); err != nil {
-			log.Printf("Warning: Failed to set custom headers: %v", err)
-		}
+	// Start the crawl engine, bounded by the timeout for the whole crawl
+	ctx, cancel := context.WithTimeout(context.Background(), crawlTimeout)
+	defer cancel()
+	defer engine.Close()
+	ctx, err = engine.Start(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Navigate to the page with retry logic
-	fmt.Printf("Loading initial page...\n")
+	slog.Info("Loading initial page", "url", targetURL)
+	capture.Traffic.SetPage(NewRequestFromURL(targetURL, capture.TargetHost, 0))
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		if attempt > 1 {
-			fmt.Printf("   ⏳ Retry attempt %d/%d...\n", attempt, maxRetries)
+			slog.Info("Retrying initial page", "url", targetURL, "attempt", attempt, "max_attempts", maxRetries)
 			time.Sleep(2 * time.Second) // Wait before retry
 		}
 
-		err := chromedp.Run(ctx, chromedp.Navigate(targetURL))
+		started := time.Now()
+		_, err := engine.Load(ctx, targetURL)
 		if err == nil {
 			break // Success
 		}
+		if errors.Is(err, errBlocked) {
+			return fmt.Errorf("%s is blocked by the interception rules", targetURL)
+		}
+		slog.Warn("Failed to load initial page", "url", targetURL, "attempt", attempt, "duration", time.Since(started), "error", failureReason(err, capture.PageTimeout))
 
 		if attempt == maxRetries {
-			log.Fatal("Failed to navigate after all retry attempts")
+			return fmt.Errorf("failed to navigate to %s after %d attempts", targetURL, maxRetries)
 		}
 	}
 
-	// Wait for page to load completely
-	time.Sleep(2 * time.Second)
-
-	// Wait a bit more for any additional requests
-	time.Sleep(1 * time.Second)
-
 	// Capture the final HTML content of the page
-	fmt.Printf("Capturing initial page content...\n")
-	var finalHTML string
-	if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &finalHTML)); err != nil {
-		log.Printf("Warning: Could not capture final page HTML: %v", err)
+	slog.Debug("Capturing initial page content", "url", targetURL)
+	if finalHTML, err := engine.HTML(ctx); err != nil {
+		slog.Warn("Could not capture final page HTML", "url", targetURL, "error", err)
 	} else {
 		if len(finalHTML) > 0 {
 			// Save the final HTML as a separate file
 			finalHTMLFile := filepath.Join(outputDir, "final_page.html")
 			if err := os.WriteFile(finalHTMLFile, []byte(finalHTML), 0644); err != nil {
-				log.Printf("Failed to write final HTML file: %v", err)
+				slog.Error("Failed to write final HTML file", "file", finalHTMLFile, "error", err)
 			} else {
-				fmt.Printf("   ✅ Saved initial page (%d bytes)\n", len(finalHTML))
+				slog.Info("Saved initial page", "url", targetURL, "bytes", len(finalHTML))
 			}
 		}
 	}
 
 	// Start crawling process
-	fmt.Printf("Starting crawl process (max depth: %d)...\n", capture.MaxDepth)
+	slog.Info("Starting crawl", "max_depth", capture.MaxDepth, "strategy", strategy)
+
+	// Report progress on the status line, or in the log when it isn't a terminal
+	stopProgress := make(chan struct{})
+	if display != nil {
+		display.Start(progressRefresh)
+	} else if progressEnabled() {
+		go logProgress(metrics, progressLogInterval, stopProgress)
+	}
 
-	// Initialize crawl queue with the initial URL
-	crawlQueue := []*Request{NewRequestFromURL(targetURL, capture.TargetHost, 0)}
+	// Initialize crawl frontier with the initial URL
+	crawlQueue.Push(NewRequestFromURL(targetURL, capture.TargetHost, 0))
+	queuedURLs := map[string]bool{targetURL: true}
 	processedURLs := make(map[string]bool)
+	fetchedResources := make(map[string]bool)
 
-	for len(crawlQueue) > 0 {
-		// Get next job from queue
-		job := crawlQueue[0]
-		crawlQueue = crawlQueue[1:]
+	// queueURL adds an in-scope URL to the crawl queue unless it was already
+	// crawled or queued, or its path template has been crawled enough
+	queueURL := func(linkURL, source, tag, attribute string, depth int) bool {
+		if !isSameDomain(capture.TargetHost, linkURL) || processedURLs[linkURL] || queuedURLs[linkURL] {
+			return false
+		}
+		if !capture.Templates.Allow(linkURL) {
+			return false
+		}
+		crawlQueue.Push(NewRequestFromResponse(linkURL, source, tag, attribute, &ResponseData{URL: source}, capture.TargetHost, depth))
+		queuedURLs[linkURL] = true
+		return true
+	}
+
+	// Endpoints found in reconstructed sources are crawled like links of the map
+	queueSourceEndpoints := func(job *Request, mapURL string, endpoints []string) {
+		if job.Depth >= capture.MaxDepth {
+			return
+		}
+		queuedCount := 0
+		for _, endpoint := range endpoints {
+			if queueURL(endpoint, mapURL, "sourcemap", "source", job.Depth+1) {
+				queuedCount++
+			}
+		}
+		if queuedCount > 0 {
+			slog.Debug("Queued source map endpoints", "url", mapURL, "depth", job.Depth+1, "count", queuedCount)
+		}
+	}
+
+	for crawlQueue.Len() > 0 {
+		metrics.SetQueueDepth(crawlQueue.Len())
+
+		// Stop once the page budget is spent
+		if maxPages > 0 && len(processedURLs) >= maxPages {
+			slog.Info("Page budget reached", "max_pages", maxPages, "remaining", crawlQueue.Len())
+			break
+		}
+
+		// Get next job from the frontier
+		job := crawlQueue.Pop()
 
 		// Skip if already processed
 		if processedURLs[job.URL] {
@@ -296,105 +603,167 @@ func main() {
 		}
 
 		processedURLs[job.URL] = true
-		fmt.Printf("\nCrawling [%d/%d]: %s\n", job.Depth+1, capture.MaxDepth+1, job.URL)
-		if job.Source != "" {
-			fmt.Printf("   From: %s\n", job.Source)
-		}
+		capture.Inventory.AddURL(job.Method, job.URL, job.Source, SourceLink)
+		slog.Info("Crawling", "url", job.URL, "depth", job.Depth, "source", job.Source)
 
 		// Navigate to the URL with retry logic
+		capture.Traffic.SetPage(job)
+		capture.APICalls.Reset()
 		var navigateErr error
+		var pageResponse *PageResponse
+		var loadTime time.Duration
+		attempts := 0
 		for attempt := 1; attempt <= maxRetries; attempt++ {
 			if attempt > 1 {
-				fmt.Printf("   Retry %d/%d...\n", attempt, maxRetries)
+				slog.Info("Retrying page", "url", job.URL, "depth", job.Depth, "attempt", attempt, "max_attempts", maxRetries)
 				time.Sleep(1 * time.Second)
 			}
 
-			navigateErr = chromedp.Run(ctx, chromedp.Navigate(job.URL))
-			if navigateErr == nil {
-				break // Success
+			attempts = attempt
+			started := time.Now()
+			pageResponse, navigateErr = engine.Load(ctx, job.URL)
+			loadTime = time.Since(started)
+			if navigateErr == nil || errors.Is(navigateErr, errBlocked) || ctx.Err() != nil {
+				break // Success, blocked, or the crawl itself has run out of time
 			}
 		}
+		capture.Stats.AddRetries(attempts - 1)
+		metrics.AddRetries(attempts - 1)
 
+		if errors.Is(navigateErr, errBlocked) {
+			slog.Debug("Skipped blocked page", "url", job.URL, "depth", job.Depth)
+			continue
+		}
 		if navigateErr != nil {
-			fmt.Printf("   Failed to load: %v\n", navigateErr)
+			slog.Warn("Failed to load page", "url", job.URL, "depth", job.Depth, "attempt", attempts, "duration", loadTime, "error", failureReason(navigateErr, capture.PageTimeout))
+			capture.recordFailure(FailurePage, job, navigateErr, capture.PageTimeout, attempts)
+			if ctx.Err() != nil {
+				break
+			}
 			continue
 		}
 
-		// Wait for page to load
-		time.Sleep(1 * time.Second)
+		status := pageResponse.Status
+		capture.Graph.AddPage(job, status)
+		capture.Stats.AddPage(job, status, loadTime)
+		metrics.ObservePage(loadTime)
 
 		// Get the page HTML
-		var pageHTML string
-		if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &pageHTML)); err != nil {
-			fmt.Printf("   Failed to get HTML: %v\n", err)
+		pageHTML, err := engine.HTML(ctx)
+		if err != nil {
+			slog.Warn("Failed to get page HTML", "url", job.URL, "depth", job.Depth, "error", failureReason(err, capture.ScriptTimeout))
+			capture.recordFailure(FailureScript, job, err, capture.ScriptTimeout, 1)
 			continue
 		}
 
+		// Links are extracted from the page with its shadow roots flattened, and its iframes.
+		// Without them, the page HTML still gives the page's own links.
+		frames, err := engine.Frames(ctx)
+		if err != nil {
+			slog.Warn("Failed to get page frames", "url", job.URL, "depth", job.Depth, "error", failureReason(err, capture.ScriptTimeout))
+			capture.recordFailure(FailureScript, job, err, capture.ScriptTimeout, 1)
+			frames = []PageFrame{{URL: job.URL, HTML: pageHTML}}
+		}
+		links, resources, forms := extractFromFrames(frames, job.URL, capture.Resolver)
+		if len(frames) > 1 {
+			slog.Debug("Found iframes", "url", job.URL, "count", len(frames)-1)
+		}
+
 		// Save the page HTML as a response
 		if len(pageHTML) > 0 {
-			responseData := ResponseData{
-				URL:      job.URL,
-				Body:     pageHTML,
-				MimeType: "text/html",
-			}
-			capture.Responses = append(capture.Responses, responseData)
-			fmt.Printf("   Page saved (%d bytes)\n", len(pageHTML))
+			responseData := NewResponseData(job.URL, []byte(pageHTML), pageResponse.ContentType)
+			capture.addResponse(responseData)
+			slog.Info("Page saved", "url", job.URL, "depth", job.Depth, "status", status, "attempt", attempts, "duration", loadTime, "bytes", len(pageHTML))
+
+			// Screenshot and PDF are taken before resources navigate the tab away
+			engine.CapturePage(ctx, job)
 
-			// Extract and save additional resources
-			resources := extractResources(pageHTML, job.URL)
+			// Save additional resources
 			if len(resources) > 0 {
-				fmt.Printf("   Found %d resources\n", len(resources))
+				slog.Debug("Found resources", "url", job.URL, "count", len(resources))
 
 				// Fetch and save resources that are on the same domain
 				savedResources := 0
-				for _, resource := range resources {
-					if isSameDomain(capture.TargetHost, resource) {
-						resourceBody, resourceMimeType := fetchResource(ctx, resource)
+				// Stylesheets add their own references, so the list grows as it is walked
+				for i := 0; i < len(resources); i++ {
+					resource := resources[i].URL
+					capture.Inventory.AddURL(http.MethodGet, resource, job.URL, SourceResource)
+					if !isSameDomain(capture.TargetHost, resource) {
+						capture.Stats.AddOutOfScope(resource)
+					}
+					// Shared resources such as bundles are fetched only once
+					if isSameDomain(capture.TargetHost, resource) && !fetchedResources[resource] {
+						fetchedResources[resource] = true
+						resourceBody, resourceContentType, err := engine.FetchResource(ctx, resource)
+						if errors.Is(err, errBlocked) {
+							slog.Debug("Skipped blocked resource", "url", resource)
+							continue
+						}
+						if err != nil {
+							resourceJob := &Request{URL: resource, Source: job.URL, Depth: job.Depth}
+							capture.recordFailure(FailureResource, resourceJob, err, capture.ResourceTimeout, 1)
+							continue
+						}
 
 						// Create a resource response entry
-						resourceData := ResponseData{
-							URL:      resource,
-							Body:     resourceBody,
-							MimeType: resourceMimeType,
-						}
-						capture.Responses = append(capture.Responses, resourceData)
+						resourceData := NewResponseData(resource, resourceBody, resourceContentType)
+						capture.addResponse(resourceData)
 						savedResources++
+
+						// Background images, fonts and imports resolve against the stylesheet
+						if isStylesheet(resourceData.MimeType) {
+							resources = append(resources, extractCSSLinks(string(resourceBody), resource, capture.Resolver)...)
+						}
+
+						// Source maps are fetched like resources, or decoded when inlined
+						if resources[i].Tag == "sourcemap" {
+							queueSourceEndpoints(job, resource, capture.addSourceMap(resource, resourceBody))
+						} else if isSourceMapped(resourceData.MimeType) {
+							if mapURL := sourceMapURL(string(resourceBody), resource); strings.HasPrefix(mapURL, "data:") {
+								if mapBody, err := decodeDataSourceMap(mapURL); err == nil {
+									queueSourceEndpoints(job, resource, capture.addSourceMap(resource, mapBody))
+								}
+							} else if mapURL != "" {
+								resources = append(resources, LinkInfo{URL: mapURL, Tag: "sourcemap", Resolution: ResolutionStandard})
+							}
+						}
 					}
 				}
 				if savedResources > 0 {
-					fmt.Printf("   Saved %d resources\n", savedResources)
+					slog.Debug("Saved resources", "url", job.URL, "count", savedResources)
 				}
 			}
 		}
 
-		// Extract links from the page with metadata
-		links := extractLinksWithMetadata(pageHTML, job.URL)
+		// Record forms for the endpoint inventory
+		for _, form := range forms {
+			capture.Inventory.AddForm(form, job.URL)
+		}
+
+		// Follow the links of the page and its iframes
 		if len(links) > 0 {
-			fmt.Printf("   Found %d links\n", len(links))
+			slog.Debug("Found links", "url", job.URL, "count", len(links))
+			for _, linkInfo := range links {
+				// Guessed URLs only enter the inventory once they are crawled
+				if linkInfo.Resolution != ResolutionSpeculative {
+					capture.Inventory.AddURL(http.MethodGet, linkInfo.URL, job.URL, SourceLink)
+				}
+				capture.Graph.AddLink(job, linkInfo)
+				if !isSameDomain(capture.TargetHost, linkInfo.URL) {
+					capture.Stats.AddOutOfScope(linkInfo.URL)
+				}
+			}
 
 			// Add new URLs to crawl queue if within depth limit
 			if job.Depth < capture.MaxDepth {
 				queuedCount := 0
 				for _, linkInfo := range links {
-					if isSameDomain(capture.TargetHost, linkInfo.URL) && !processedURLs[linkInfo.URL] {
-						// Check if this URL is already in the queue
-						alreadyQueued := false
-						for _, queuedJob := range crawlQueue {
-							if queuedJob.URL == linkInfo.URL {
-								alreadyQueued = true
-								break
-							}
-						}
-
-						if !alreadyQueued {
-							newRequest := NewRequestFromResponse(linkInfo.URL, job.URL, linkInfo.Tag, linkInfo.Attribute, &ResponseData{URL: job.URL}, capture.TargetHost, job.Depth+1)
-							crawlQueue = append(crawlQueue, newRequest)
-							queuedCount++
-						}
+					if queueURL(linkInfo.URL, job.URL, linkInfo.Tag, linkInfo.Attribute, job.Depth+1) {
+						queuedCount++
 					}
 				}
 				if queuedCount > 0 {
-					fmt.Printf("   Queued %d new URLs for crawling\n", queuedCount)
+					slog.Debug("Queued new URLs", "url", job.URL, "depth", job.Depth+1, "count", queuedCount)
 				}
 			}
 		}
@@ -403,10 +772,42 @@ func main() {
 		time.Sleep(500 * time.Millisecond)
 	}
 
-	// Save all captured responses
-	capture.SaveResponses()
+	metrics.SetQueueDepth(crawlQueue.Len())
+	close(stopProgress)
+	if display != nil {
+		display.Stop()
+	}
+
+	// Let pending network captures finish before writing output
+	capture.Traffic.Wait()
+	if warcWriter != nil {
+		if err := warcWriter.Close(); err != nil {
+			slog.Error("Failed to close WARC file", "file", warcFile, "error", err)
+		} else {
+			slog.Info("Wrote WARC file", "file", warcFile)
+		}
+	}
 
-	fmt.Printf("\nCrawl complete! Saved %d responses to %s\n", len(capture.Responses), outputDir)
+	// Save all captured responses
+	if capture.Mirror {
+		capture.SaveMirror()
+	} else {
+		capture.SaveResponses()
+	}
+	capture.SaveFailures()
+	capture.SaveEndpoints()
+	capture.SaveTemplates()
+	capture.SaveFindings()
+	capture.SaveMimeMismatches()
+	capture.SaveGallery()
+	capture.SaveGraph()
+	capture.SaveSources()
+	capture.SaveStreams()
+	capture.SaveAPICalls()
+	capture.SaveSummary(stderr)
+
+	slog.Info("Crawl complete", "responses", len(capture.Responses), "output_dir", outputDir)
+	return nil
 }
 
 // stringSlice type for flag parsing
@@ -421,39 +822,76 @@ func (s *stringSlice) Set(value string) error {
 	return nil
 }
 
+// addResponse stores a captured response, writes its URL to the output
+// stream and scans it for secrets
+func (nc *NetworkCapture) addResponse(response ResponseData) {
+	nc.Responses = append(nc.Responses, response)
+	if nc.Mirror {
+		nc.mirrorLocalPath(response.URL, response.MimeType)
+	}
+	if nc.Metrics != nil {
+		nc.Metrics.ObserveResponse(len(response.Body))
+	}
+	if nc.Output != nil {
+		fmt.Fprintln(nc.Output, response.URL)
+	}
+
+	if nc.Secrets != nil {
+		if found := nc.Secrets.Scan(response.URL, string(response.Body)); found > 0 {
+			slog.Warn("Found potential secrets", "url", response.URL, "count", found)
+		}
+	}
+}
+
+// SaveResponses stores each response body once in a content-addressed blob
+// directory and writes an index mapping URLs to their blobs
 func (nc *NetworkCapture) SaveResponses() {
-	fmt.Printf("\nSaving responses...\n")
-
-	// Save individual response content files
-	savedCount := 0
-	for i, response := range nc.Responses {
-		// Create a safe filename
-		safeURL := strings.ReplaceAll(response.URL, "://", "_")
-		safeURL = strings.ReplaceAll(safeURL, "/", "_")
-		safeURL = strings.ReplaceAll(safeURL, "?", "_")
-		safeURL = strings.ReplaceAll(safeURL, "&", "_")
-		safeURL = strings.ReplaceAll(safeURL, "=", "_")
-
-		// Limit filename length
-		if len(safeURL) > 100 {
-			safeURL = safeURL[:100]
-		}
-
-		// Save response content as separate file with appropriate extension
-		if len(response.Body) > 0 {
-			extension := getFileExtension(response.MimeType, []byte(response.Body))
-			contentFilename := fmt.Sprintf("%d_%s%s", i+1, safeURL, extension)
-			contentFilepath := filepath.Join(nc.OutputDir, contentFilename)
-
-			if err := os.WriteFile(contentFilepath, []byte(response.Body), 0644); err != nil {
-				log.Printf("Failed to write content file %d: %v", i+1, err)
-			} else {
-				savedCount++
-			}
+	slog.Debug("Saving responses", "count", len(nc.Responses))
+
+	index := make([]BlobIndexEntry, 0, len(nc.Responses))
+	indexed := make(map[string]bool)
+	blobs := make(map[string]bool)
+	var totalBytes, storedBytes int
+	for _, response := range nc.Responses {
+		if len(response.Body) == 0 {
+			continue
+		}
+
+		hash := blobHash(response.Body)
+		if indexed[response.URL+" "+hash] {
+			continue
+		}
+		indexed[response.URL+" "+hash] = true
+		totalBytes += len(response.Body)
+
+		blobPath, err := writeBlob(nc.OutputDir, hash, response.Body)
+		if err != nil {
+			slog.Error("Failed to write blob", "url", response.URL, "error", err)
+			continue
+		}
+		if !blobs[hash] {
+			blobs[hash] = true
+			storedBytes += len(response.Body)
 		}
+
+		index = append(index, BlobIndexEntry{
+			URL:      response.URL,
+			SHA256:   hash,
+			Blob:     blobPath,
+			Size:     len(response.Body),
+			MimeType: response.MimeType,
+
+			DeclaredMimeType: response.DeclaredMimeType,
+			DetectedMimeType: response.DetectedMimeType,
+		})
 	}
 
-	fmt.Printf("   Saved %d files\n", savedCount)
+	if err := writeBlobIndex(nc.OutputDir, index); err != nil {
+		slog.Error("Failed to write blob index", "error", err)
+		return
+	}
+
+	slog.Info("Indexed responses", "responses", len(index), "blobs", len(blobs), "stored_bytes", storedBytes, "total_bytes", totalBytes)
 }
 
 func normalizeHost(host string) string {
@@ -509,8 +947,9 @@ func extractLinks(htmlContent string, baseURL string) []string {
 	return links
 }
 
-// Helper function to extract links with metadata from HTML content
-func extractLinksWithMetadata(htmlContent string, baseURL string) []LinkInfo {
+// Helper function to extract links with metadata from HTML content.
+// Hrefs are resolved under the resolver's policy.
+func extractLinksWithMetadata(htmlContent string, baseURL string, resolver *URLResolver) []LinkInfo {
 	var links []LinkInfo
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
@@ -535,19 +974,12 @@ func extractLinksWithMetadata(htmlContent string, baseURL string) []LinkInfo {
 
 			for _, attr := range n.Attr {
 				if attr.Key == "href" {
-					link := attr.Val
-					// Use enhanced URL resolution that tries both current directory and root
-					resolvedURLs := resolveURLWithFallback(link, baseURL)
-					if len(resolvedURLs) > 0 {
-						linkInfo.URL = resolvedURLs[0] // Use the first (primary) resolution
-						links = append(links, linkInfo)
-
-						// If there are additional resolutions, create additional link entries
-						for i := 1; i < len(resolvedURLs); i++ {
-							additionalLink := linkInfo
-							additionalLink.URL = resolvedURLs[i]
-							links = append(links, additionalLink)
-						}
+					// Each resolution becomes a link entry, the standard one first
+					for _, resolved := range resolver.Resolve(attr.Val, baseURL) {
+						resolvedLink := linkInfo
+						resolvedLink.URL = resolved.URL
+						resolvedLink.Resolution = resolved.Resolution
+						links = append(links, resolvedLink)
 					}
 					break
 				}
@@ -582,11 +1014,11 @@ func (nc *NetworkCapture) crawlURL(ctx context.Context, job *Request) {
 	}
 
 	nc.VisitedURLs[job.URL] = true
-	fmt.Printf("Crawling (depth %d): %s\n", job.Depth, job.URL)
+	slog.Info("Crawling", "url", job.URL, "depth", job.Depth)
 
 	// Navigate to the URL
 	if err := chromedp.Run(ctx, chromedp.Navigate(job.URL)); err != nil {
-		log.Printf("Failed to navigate to %s: %v", job.URL, err)
+		slog.Warn("Failed to navigate", "url", job.URL, "error", err)
 		return
 	}
 
@@ -596,13 +1028,13 @@ func (nc *NetworkCapture) crawlURL(ctx context.Context, job *Request) {
 	// Get the page HTML
 	var pageHTML string
 	if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &pageHTML)); err != nil {
-		log.Printf("Failed to get HTML from %s: %v", job.URL, err)
+		slog.Warn("Failed to get page HTML", "url", job.URL, "error", err)
 		return
 	}
 
 	// Extract links from the page
 	links := extractLinks(pageHTML, job.URL)
-	fmt.Printf("  Found %d links on %s\n", len(links), job.URL)
+	slog.Debug("Found links", "url", job.URL, "count", len(links))
 
 	// Queue new URLs for crawling
 	for _, link := range links {
@@ -614,43 +1046,55 @@ func (nc *NetworkCapture) crawlURL(ctx context.Context, job *Request) {
 	}
 }
 
-// Helper function to extract additional resources from HTML
-func extractResources(htmlContent string, baseURL string) []string {
-	var resources []string
+// Helper function to extract additional resources from HTML, including
+// url() and @import references in inline CSS.
+// Sources are resolved under the resolver's policy.
+func extractResources(htmlContent string, baseURL string, resolver *URLResolver) []LinkInfo {
+	var resources []LinkInfo
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return resources
 	}
 
+	addResource := func(n *html.Node, attribute string) {
+		for _, attr := range n.Attr {
+			if attr.Key == attribute {
+				for _, resolved := range resolver.Resolve(attr.Val, baseURL) {
+					resources = append(resources, LinkInfo{
+						URL:        resolved.URL,
+						Tag:        n.Data,
+						Attribute:  attribute,
+						Resolution: resolved.Resolution,
+					})
+				}
+				break
+			}
+		}
+	}
+
 	var extract func(*html.Node)
 	extract = func(n *html.Node) {
 		if n.Type == html.ElementNode {
 			switch n.Data {
 			case "script":
-				for _, attr := range n.Attr {
-					if attr.Key == "src" {
-						resolvedURLs := resolveURLWithFallback(attr.Val, baseURL)
-						resources = append(resources, resolvedURLs...)
-						break
-					}
-				}
+				addResource(n, "src")
 			case "link":
-				for _, attr := range n.Attr {
-					if attr.Key == "href" {
-						resolvedURLs := resolveURLWithFallback(attr.Val, baseURL)
-						resources = append(resources, resolvedURLs...)
-						break
-					}
-				}
+				addResource(n, "href")
 			case "img":
-				for _, attr := range n.Attr {
-					if attr.Key == "src" {
-						resolvedURLs := resolveURLWithFallback(attr.Val, baseURL)
-						resources = append(resources, resolvedURLs...)
-						break
+				addResource(n, "src")
+			case "style":
+				// Inline stylesheets reference images, fonts and imports
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == html.TextNode {
+						resources = append(resources, extractCSSLinks(c.Data, baseURL, resolver)...)
 					}
 				}
 			}
+			for _, attr := range n.Attr {
+				if attr.Key == "style" {
+					resources = append(resources, extractCSSLinks(attr.Val, baseURL, resolver)...)
+				}
+			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			extract(c)
@@ -714,33 +1158,6 @@ func resolveURLWithFallback(urlStr, baseURL string) []string {
 	return resolvedURLs
 }
 
-// Helper function to determine MIME type from URL
-func getMimeTypeFromURL(urlStr string) string {
-	urlStr = strings.ToLower(urlStr)
-	switch {
-	case strings.HasSuffix(urlStr, ".js"):
-		return "application/javascript"
-	case strings.HasSuffix(urlStr, ".css"):
-		return "text/css"
-	case strings.HasSuffix(urlStr, ".png"):
-		return "image/png"
-	case strings.HasSuffix(urlStr, ".jpg") || strings.HasSuffix(urlStr, ".jpeg"):
-		return "image/jpeg"
-	case strings.HasSuffix(urlStr, ".gif"):
-		return "image/gif"
-	case strings.HasSuffix(urlStr, ".svg"):
-		return "image/svg+xml"
-	case strings.HasSuffix(urlStr, ".json"):
-		return "application/json"
-	case strings.HasSuffix(urlStr, ".xml"):
-		return "application/xml"
-	case strings.HasSuffix(urlStr, ".pdf"):
-		return "application/pdf"
-	default:
-		return "text/plain"
-	}
-}
-
 // Helper function to determine file extension based on MIME type and content
 func getFileExtension(mimeType string, body []byte) string {
 	mimeType = strings.ToLower(mimeType)
@@ -751,6 +1168,9 @@ func getFileExtension(mimeType string, body []byte) string {
 		return ".html"
 	case strings.Contains(mimeType, "json"):
 		return ".json"
+	// image/svg+xml is SVG before it is XML
+	case strings.Contains(mimeType, "svg"):
+		return ".svg"
 	case strings.Contains(mimeType, "xml"):
 		return ".xml"
 	case strings.Contains(mimeType, "javascript") || strings.Contains(mimeType, "js"):
@@ -763,73 +1183,89 @@ func getFileExtension(mimeType string, body []byte) string {
 		return ".jpg"
 	case strings.Contains(mimeType, "gif"):
 		return ".gif"
-	case strings.Contains(mimeType, "svg"):
-		return ".svg"
 	case strings.Contains(mimeType, "pdf"):
 		return ".pdf"
+	case strings.Contains(mimeType, "wasm"):
+		return ".wasm"
+	case strings.Contains(mimeType, "woff2"):
+		return ".woff2"
+	case strings.Contains(mimeType, "woff"):
+		return ".woff"
+	case strings.Contains(mimeType, "font/ttf"):
+		return ".ttf"
+	case strings.Contains(mimeType, "font/otf"):
+		return ".otf"
+	case strings.Contains(mimeType, "webp"):
+		return ".webp"
+	case strings.Contains(mimeType, "icon"):
+		return ".ico"
 	case strings.Contains(mimeType, "text/plain"):
 		return ".txt"
 	}
 
 	// If MIME type doesn't help, try to detect from content
-	bodyStr := strings.ToLower(string(body))
-	if strings.Contains(bodyStr, "<html") || strings.Contains(bodyStr, "<!doctype") {
-		return ".html"
-	}
-	if strings.HasPrefix(strings.TrimSpace(bodyStr), "{") || strings.HasPrefix(strings.TrimSpace(bodyStr), "[") {
-		return ".json"
-	}
-	if strings.Contains(bodyStr, "<?xml") || strings.HasPrefix(strings.TrimSpace(bodyStr), "<") {
-		return ".xml"
+	if len(body) > 0 {
+		if detected := detectMimeType(body, ""); !isGenericMimeType(detected) {
+			return getFileExtension(detected, nil)
+		}
 	}
 
 	// Default to .txt for unknown types
 	return ".txt"
 }
 
-// Helper function to fetch resource content
-func fetchResource(ctx context.Context, resourceURL string) (string, string) {
-	// Try to fetch the resource using Chrome DevTools Protocol
-	var resourceBody string
-	var resourceMimeType string
+// Helper function to fetch resource content.
+// It returns the body and the declared Content-Type of the response.
+func (nc *NetworkCapture) fetchResource(ctx context.Context, resourceURL string) ([]byte, string, error) {
+	if nc.Interceptor.Blocked(resourceURL, resourceTypeFor(resourceURL)) {
+		return nil, "", errBlocked
+	}
+	mode := FetchBrowser
+	if nc.Fetcher != nil {
+		mode = nc.Fetcher.ModeFor(resourceURL)
+	}
+	if mode == FetchBrowser {
+		return nc.fetchResourceInBrowser(ctx, resourceURL)
+	}
 
-	// Create a timeout context for resource fetching
-	resourceCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+	exchange, err := nc.fetchHTTP(ctx, resourceURL, nc.ResourceTimeout)
+	if err != nil {
+		slog.Warn("Failed to fetch resource", "url", resourceURL, "error", failureReason(err, nc.ResourceTimeout))
+		return nil, "", err
+	}
+	nc.recordExchange(exchange)
+	return exchange.Body, exchange.ResponseHeaders["Content-Type"], nil
+}
 
-	// Use chromedp to fetch the resource
-	err := chromedp.Run(resourceCtx, chromedp.ActionFunc(func(ctx context.Context) error {
-		// Enable network events if not already enabled
-		if err := network.Enable().Do(ctx); err != nil {
-			return err
-		}
+// fetchResourceInBrowser fetches a resource by navigating the browser to it
+func (nc *NetworkCapture) fetchResourceInBrowser(ctx context.Context, resourceURL string) ([]byte, string, error) {
+	// Try to fetch the resource using Chrome DevTools Protocol
+	var resourceBody []byte
+	var resourceContentType string
 
+	// Use chromedp to fetch the resource, bounded by the resource timeout
+	err := runWithTimeout(ctx, nc.ResourceTimeout, chromedp.ActionFunc(func(ctx context.Context) error {
 		// Navigate to the resource
-		if err := chromedp.Run(ctx, chromedp.Navigate(resourceURL)); err != nil {
+		resp, err := chromedp.RunResponse(ctx, chromedp.Navigate(resourceURL))
+		if err != nil {
 			return err
 		}
+		resourceContentType = contentTypeOf(resp)
 
-		// Wait a bit for the resource to load
-		time.Sleep(500 * time.Millisecond)
-
-		// Get the page content
-		if err := chromedp.Run(ctx, chromedp.OuterHTML("html", &resourceBody)); err != nil {
-			// If it's not HTML, try to get the raw content
-			if err := chromedp.Run(ctx, chromedp.Text("body", &resourceBody)); err != nil {
-				return err
-			}
+		// Read the raw response bytes rather than the rendered document,
+		// so binary resources such as images and fonts survive intact
+		requestID, ok := nc.Traffic.DocumentRequestID(resourceURL)
+		if !ok {
+			return fmt.Errorf("no network request recorded for %s", resourceURL)
 		}
-
-		// Try to determine MIME type from URL
-		resourceMimeType = getMimeTypeFromURL(resourceURL)
-
-		return nil
+		resourceBody, err = network.GetResponseBody(requestID).Do(ctx)
+		return err
 	}))
 
 	if err != nil {
-		log.Printf("Failed to fetch resource %s: %v", resourceURL, err)
-		return "", getMimeTypeFromURL(resourceURL)
+		slog.Warn("Failed to fetch resource", "url", resourceURL, "error", failureReason(err, nc.ResourceTimeout))
+		return nil, "", err
 	}
 
-	return resourceBody, resourceMimeType
+	return resourceBody, resourceContentType, nil
 }
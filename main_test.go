@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "example.com"},
+		{"WWW.Example.COM", "example.com"},
+		{"www.example.com:8080", "example.com"},
+		{"api.example.com", "api.example.com"},
+		{"127.0.0.1:3000", "127.0.0.1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeHost(tt.host); got != tt.want {
+			t.Errorf("normalizeHost(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestIsSameDomain(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/page", true},
+		{"http://www.example.com:8080/page", true},
+		{"https://api.example.com/page", false},
+		{"https://example.org/page", false},
+		{"mailto:admin@example.com", false},
+		{"/relative", false},
+	}
+	for _, tt := range tests {
+		if got := isSameDomain("example.com", tt.url); got != tt.want {
+			t.Errorf("isSameDomain(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestIsSameOrSubdomain(t *testing.T) {
+	tests := []struct {
+		candidate string
+		want      bool
+	}{
+		{"example.com", true},
+		{"api.example.com", true},
+		{"www.example.com", true},
+		{"notexample.com", false},
+		{"example.com.evil.net", false},
+	}
+	for _, tt := range tests {
+		if got := isSameOrSubdomain("example.com", tt.candidate); got != tt.want {
+			t.Errorf("isSameOrSubdomain(%q) = %v, want %v", tt.candidate, got, tt.want)
+		}
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	base := "https://example.com/docs/guide/index.html"
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://other.com/x", "https://other.com/x"},
+		{"page.html", "https://example.com/docs/guide/page.html"},
+		{"../api/", "https://example.com/docs/api/"},
+		{"/root.html", "https://example.com/root.html"},
+		{"//cdn.example.com/app.js", "https://cdn.example.com/app.js"},
+		{"?q=1", "https://example.com/docs/guide/index.html?q=1"},
+		{"#top", "https://example.com/docs/guide/index.html#top"},
+	}
+	for _, tt := range tests {
+		if got := resolveURL(tt.url, base); got != tt.want {
+			t.Errorf("resolveURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestResolveURLWithFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		base string
+		want []string
+	}{
+		{
+			name: "absolute URL is kept",
+			url:  "https://other.com/x",
+			base: "https://example.com/a/b",
+			want: []string{"https://other.com/x"},
+		},
+		{
+			name: "root-relative path has one resolution",
+			url:  "/login",
+			base: "https://example.com/a/b",
+			want: []string{"https://example.com/login"},
+		},
+		{
+			name: "relative path also tries the root",
+			url:  "js/app.js",
+			base: "https://example.com/docs/page.html",
+			want: []string{"https://example.com/docs/js/app.js", "https://example.com/js/app.js"},
+		},
+		{
+			name: "relative path on a root page has one resolution",
+			url:  "about.html",
+			base: "https://example.com/index.html",
+			want: []string{"https://example.com/about.html"},
+		},
+		{
+			name: "parent path resolves against both",
+			url:  "../img/logo.png",
+			base: "https://example.com/a/b/c.html",
+			want: []string{"https://example.com/a/img/logo.png", "https://example.com/img/logo.png"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolveURLWithFallback(tt.url, tt.base)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveURLWithFallback(%q, %q) = %v, want %v", tt.url, tt.base, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractLinksWithMetadata(t *testing.T) {
+	page := `<html><body>
+		<a href="/about">About <b>us</b></a>
+		<a href="docs/intro.html">Intro</a>
+		<a href="https://other.com/">Elsewhere</a>
+		<a name="anchor-only">No href</a>
+	</body></html>`
+
+	got := extractLinksWithMetadata(page, "https://example.com/blog/post.html", nil)
+	want := []LinkInfo{
+		{URL: "https://example.com/about", Tag: "a", Attribute: "href", Text: "About", Resolution: ResolutionStandard},
+		{URL: "https://example.com/blog/docs/intro.html", Tag: "a", Attribute: "href", Text: "Intro", Resolution: ResolutionStandard},
+		{URL: "https://other.com/", Tag: "a", Attribute: "href", Text: "Elsewhere", Resolution: ResolutionLiteral},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractLinksWithMetadata() =\n%v\nwant\n%v", got, want)
+	}
+
+	resolver, err := NewURLResolver(ResolveFallback, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = extractLinksWithMetadata(page, "https://example.com/blog/post.html", resolver)
+	want = append(want[:2:2],
+		LinkInfo{URL: "https://example.com/docs/intro.html", Tag: "a", Attribute: "href", Text: "Intro", Resolution: ResolutionSpeculative},
+		want[2])
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractLinksWithMetadata() with fallback =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestExtractResources(t *testing.T) {
+	page := `<html><head>
+		<link rel="stylesheet" href="/css/site.css">
+		<script src="/js/app.js"></script>
+		<script>inline()</script>
+		<style>@import "print.css"; .hero { background: url(/img/hero.jpg) }</style>
+	</head><body>
+		<img src="/img/logo.png">
+		<img alt="no source">
+		<div style="background-image: url('/img/bg.png')"></div>
+	</body></html>`
+
+	got := extractResources(page, "https://example.com/", nil)
+	want := []LinkInfo{
+		{URL: "https://example.com/css/site.css", Tag: "link", Attribute: "href", Resolution: ResolutionStandard},
+		{URL: "https://example.com/js/app.js", Tag: "script", Attribute: "src", Resolution: ResolutionStandard},
+		{URL: "https://example.com/print.css", Tag: "css", Attribute: "import", Resolution: ResolutionStandard},
+		{URL: "https://example.com/img/hero.jpg", Tag: "css", Attribute: "url", Resolution: ResolutionStandard},
+		{URL: "https://example.com/img/logo.png", Tag: "img", Attribute: "src", Resolution: ResolutionStandard},
+		{URL: "https://example.com/img/bg.png", Tag: "css", Attribute: "url", Resolution: ResolutionStandard},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractResources() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestRequestURL(t *testing.T) {
+	get := &Request{Method: "GET", URL: "https://example.com/a"}
+	if got := get.RequestURL(); got != "https://example.com/a" {
+		t.Errorf("GET RequestURL() = %q", got)
+	}
+	post := &Request{Method: "POST", URL: "https://example.com/a", Body: "x=1"}
+	if got := post.RequestURL(); got != "https://example.com/a:x=1" {
+		t.Errorf("POST RequestURL() = %q", got)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if err := run(nil, &stdout, &stderr); err != errUsage {
+		t.Fatalf("run() with no URL = %v, want errUsage", err)
+	}
+	if !strings.Contains(stdout.String(), "Usage:") {
+		t.Errorf("usage message missing from stdout: %q", stdout.String())
+	}
+}
+
+func TestRunRejectsBadFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown strategy", []string{"-strategy", "sideways", "https://example.com"}},
+		{"unknown log format", []string{"-log-format", "xml", "https://example.com"}},
+		{"verbose and quiet", []string{"-v", "-q", "https://example.com"}},
+		{"bad viewport", []string{"-viewport", "wide", "https://example.com"}},
+		{"unknown resolve policy", []string{"-resolve", "guess", "https://example.com"}},
+		{"unknown engine", []string{"-engine", "firefox", "https://example.com"}},
+		{"screenshots without a browser", []string{"-engine", "http", "-screenshots", "https://example.com"}},
+		{"unknown fetch mode", []string{"-fetch", "curl", "https://example.com"}},
+		{"bad fetch rule", []string{"-fetch-rule", "image/*", "https://example.com"}},
+		{"header scope without a header", []string{"-header-scope", "api.example.com", "https://example.com"}},
+		{"missing interception rules", []string{"-intercept", "does-not-exist.json", "https://example.com"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(append(tt.args, t.TempDir()), &stdout, &stderr); err == nil {
+				t.Errorf("run(%v) succeeded, want an error", tt.args)
+			}
+		})
+	}
+}
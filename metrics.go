@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Histogram buckets for navigation latency in seconds and response sizes in bytes
+var (
+	navigationBuckets   = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	responseSizeBuckets = []float64{1 << 10, 10 << 10, 100 << 10, 1 << 20, 10 << 20}
+)
+
+// histogram is a cumulative Prometheus-style histogram
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(value float64) {
+	for i, bound := range h.buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// Metrics counts crawl activity for the progress display and the
+// Prometheus endpoint. It is safe for concurrent use.
+type Metrics struct {
+	mu           sync.Mutex
+	started      time.Time
+	pages        uint64
+	bytes        uint64
+	retries      uint64
+	failures     map[string]uint64
+	queueDepth   int
+	navigation   *histogram
+	responseSize *histogram
+}
+
+// MetricsSnapshot is a point-in-time copy of the crawl counters
+type MetricsSnapshot struct {
+	Elapsed    time.Duration
+	Pages      uint64
+	Bytes      uint64
+	Retries    uint64
+	Failures   uint64
+	QueueDepth int
+}
+
+// NewMetrics creates an empty set of crawl metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		started:      time.Now(),
+		failures:     make(map[string]uint64),
+		navigation:   newHistogram(navigationBuckets),
+		responseSize: newHistogram(responseSizeBuckets),
+	}
+}
+
+// ObservePage records a crawled page and how long its navigation took
+func (m *Metrics) ObservePage(duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pages++
+	m.navigation.observe(duration.Seconds())
+}
+
+// ObserveResponse records the size of a captured response
+func (m *Metrics) ObserveResponse(size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes += uint64(size)
+	m.responseSize.observe(float64(size))
+}
+
+// AddRetries records retries spent on a request
+func (m *Metrics) AddRetries(retries int) {
+	if retries <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retries += uint64(retries)
+}
+
+// AddFailure records a failed request of the given kind
+func (m *Metrics) AddFailure(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[kind]++
+}
+
+// SetQueueDepth records the number of URLs waiting in the frontier
+func (m *Metrics) SetQueueDepth(depth int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queueDepth = depth
+}
+
+// Snapshot returns the current counters
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := MetricsSnapshot{
+		Elapsed:    time.Since(m.started),
+		Pages:      m.pages,
+		Bytes:      m.bytes,
+		Retries:    m.retries,
+		QueueDepth: m.queueDepth,
+	}
+	for _, count := range m.failures {
+		snapshot.Failures += count
+	}
+	return snapshot
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition format
+func (m *Metrics) WritePrometheus(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writeMetricHeader(w, "crawler_pages_crawled_total", "counter", "Pages crawled.")
+	fmt.Fprintf(w, "crawler_pages_crawled_total %d\n", m.pages)
+
+	writeMetricHeader(w, "crawler_response_bytes_total", "counter", "Bytes of captured responses.")
+	fmt.Fprintf(w, "crawler_response_bytes_total %d\n", m.bytes)
+
+	writeMetricHeader(w, "crawler_retries_total", "counter", "Page navigation retries.")
+	fmt.Fprintf(w, "crawler_retries_total %d\n", m.retries)
+
+	writeMetricHeader(w, "crawler_failures_total", "counter", "Failed requests by kind.")
+	kinds := make([]string, 0, len(m.failures))
+	for kind := range m.failures {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "crawler_failures_total{kind=%q} %d\n", kind, m.failures[kind])
+	}
+
+	writeMetricHeader(w, "crawler_queue_depth", "gauge", "URLs waiting in the crawl frontier.")
+	fmt.Fprintf(w, "crawler_queue_depth %d\n", m.queueDepth)
+
+	writeMetricHeader(w, "crawler_navigation_duration_seconds", "histogram", "Page navigation latency.")
+	writeHistogram(w, "crawler_navigation_duration_seconds", m.navigation)
+
+	writeMetricHeader(w, "crawler_response_size_bytes", "histogram", "Size of captured responses.")
+	writeHistogram(w, "crawler_response_size_bytes", m.responseSize)
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeHistogram(w io.Writer, name string, h *histogram) {
+	for i, bound := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, strconv.FormatFloat(bound, 'f', -1, 64), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(h.sum, 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// ServeHTTP serves the metrics to a Prometheus scraper
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
+
+// serveMetrics exposes the metrics at /metrics on addr until the server is closed
+func serveMetrics(addr string, m *Metrics) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			slog.Error("Metrics server stopped", "error", err)
+		}
+	}()
+	slog.Info("Serving metrics", "addr", listener.Addr().String(), "path", "/metrics")
+	return server, nil
+}
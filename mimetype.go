@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+// sniffLength is how much of a body is inspected by the content rules
+const sniffLength = 1024
+
+// jsPrefixes are common ways for a script to start
+var jsPrefixes = []string{
+	"\"use strict\"", "'use strict'", "(function", "!function", ";(function", "(()",
+	"(async", "var ", "let ", "const ", "function ", "import ", "export ", "define(",
+	"window.", "self.", "document.", "(self.webpackChunk", "webpackJsonp", "//# sourceMappingURL=",
+}
+
+// jsPattern catches scripts whose first statement isn't one of jsPrefixes
+var jsPattern = regexp.MustCompile(`^(/\*[\s\S]*?\*/\s*|//[^\n]*\n\s*)*([A-Za-z_$][\w$.]*(\s*=[^=]|\()|\(|!|;|var\s|let\s|const\s|function[\s(]|import[\s{(]|export\s)`)
+
+// xssiPrefix is prepended to some JSON responses, including source maps
+const xssiPrefix = ")]}'"
+
+// mimeEquivalents maps alternative spellings of a MIME type to one name
+var mimeEquivalents = map[string]string{
+	"text/javascript":          "application/javascript",
+	"application/x-javascript": "application/javascript",
+	"application/ecmascript":   "application/javascript",
+	"text/ecmascript":          "application/javascript",
+	"text/json":                "application/json",
+	"text/xml":                 "application/xml",
+	"application/font-woff":    "font/woff",
+	"application/x-font-woff":  "font/woff",
+	"application/font-sfnt":    "font/ttf",
+	"application/x-font-ttf":   "font/ttf",
+	"application/x-font-otf":   "font/otf",
+	"image/jpg":                "image/jpeg",
+	"image/x-icon":             "image/vnd.microsoft.icon",
+}
+
+// MimeMismatch records a response whose declared type disagrees with its content
+type MimeMismatch struct {
+	URL      string `json:"url"`
+	Declared string `json:"declared"`
+	Detected string `json:"detected"`
+}
+
+// baseMimeType strips parameters such as charset and lower-cases a MIME type
+func baseMimeType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	}
+	return strings.ToLower(mediaType)
+}
+
+// canonicalMimeType maps equivalent MIME types to a single name for comparison
+func canonicalMimeType(mimeType string) string {
+	mimeType = baseMimeType(mimeType)
+	if canonical, ok := mimeEquivalents[mimeType]; ok {
+		return canonical
+	}
+	return mimeType
+}
+
+// isGenericMimeType reports whether a type says little about the content
+func isGenericMimeType(mimeType string) bool {
+	switch baseMimeType(mimeType) {
+	case "", "application/octet-stream", "text/plain", "binary/octet-stream", "application/unknown":
+		return true
+	}
+	return false
+}
+
+// detectMimeType sniffs the MIME type of a body. Magic bytes are checked with
+// http.DetectContentType, after our own rules for content it doesn't know or
+// reports as plain text: scripts, JSON, source maps, WASM and fonts.
+// The URL extension is only used when the content gives no answer.
+func detectMimeType(body []byte, urlStr string) string {
+	head := body
+	if len(head) > sniffLength {
+		head = head[:sniffLength]
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("\x00asm")):
+		return "application/wasm"
+	case bytes.HasPrefix(head, []byte("wOFF")):
+		return "font/woff"
+	case bytes.HasPrefix(head, []byte("wOF2")):
+		return "font/woff2"
+	case bytes.HasPrefix(head, []byte("OTTO")):
+		return "font/otf"
+	case bytes.HasPrefix(head, []byte("\x00\x01\x00\x00")):
+		return "font/ttf"
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")))
+	if isSourceMap(body) {
+		return "application/json"
+	}
+	if (bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("["))) && json.Valid(bytes.TrimSpace(body)) {
+		return "application/json"
+	}
+
+	sniffed := baseMimeType(http.DetectContentType(head))
+	if !isGenericMimeType(sniffed) || !isTextLike(head) {
+		return sniffed
+	}
+
+	if looksLikeJavaScript(trimmed) {
+		return "application/javascript"
+	}
+	if byExtension := baseMimeType(mimeTypeFromExtension(urlStr)); byExtension != "" {
+		return byExtension
+	}
+	return sniffed
+}
+
+// isSourceMap reports whether a body is a version 3 source map
+func isSourceMap(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	trimmed = bytes.TrimSpace(bytes.TrimPrefix(trimmed, []byte(xssiPrefix)))
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		return false
+	}
+	var sourceMap struct {
+		Version  int              `json:"version"`
+		Mappings *json.RawMessage `json:"mappings"`
+		Sections *json.RawMessage `json:"sections"`
+	}
+	if err := json.Unmarshal(trimmed, &sourceMap); err != nil {
+		return false
+	}
+	return sourceMap.Version == 3 && (sourceMap.Mappings != nil || sourceMap.Sections != nil)
+}
+
+// isTextLike reports whether a sniffed prefix is plain text
+func isTextLike(head []byte) bool {
+	return !bytes.ContainsRune(head, 0)
+}
+
+// looksLikeJavaScript applies simple heuristics to the start of a script
+func looksLikeJavaScript(trimmed []byte) bool {
+	for _, prefix := range jsPrefixes {
+		if bytes.HasPrefix(trimmed, []byte(prefix)) {
+			return true
+		}
+	}
+	return jsPattern.Match(trimmed) && bytes.ContainsAny(trimmed, ";{}()")
+}
+
+// mimeTypeFromExtension guesses a MIME type from the URL's file extension
+func mimeTypeFromExtension(urlStr string) string {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(parsedURL.Path))
+	switch ext {
+	case "":
+		return ""
+	case ".map":
+		return "application/json"
+	case ".js", ".mjs":
+		return "application/javascript"
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// resolveMimeType picks the effective MIME type of a response. The declared
+// Content-Type wins unless it is missing or generic, in which case the
+// detected type is used.
+func resolveMimeType(declared, detected string) string {
+	if !isGenericMimeType(declared) {
+		return baseMimeType(declared)
+	}
+	if detected != "" {
+		return detected
+	}
+	return baseMimeType(declared)
+}
+
+// isMimeMismatch reports whether the declared type contradicts the detected one.
+// Missing declarations and plain-text detections are not treated as mismatches.
+func isMimeMismatch(declared, detected string) bool {
+	declared = canonicalMimeType(declared)
+	detected = canonicalMimeType(detected)
+	if declared == "" || detected == "" || detected == "text/plain" || declared == detected {
+		return false
+	}
+	// Structured syntax suffixes such as application/ld+json or image/svg+xml
+	for _, suffix := range []string{"json", "xml"} {
+		if strings.HasSuffix(declared, "+"+suffix) && strings.HasSuffix(detected, "/"+suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// contentTypeOf returns the Content-Type header of a navigation response
+func contentTypeOf(resp *network.Response) string {
+	if resp == nil {
+		return ""
+	}
+	return headerValue(resp.Headers, "Content-Type")
+}
+
+// MimeMismatches lists the captured responses whose declared and detected types disagree
+func (nc *NetworkCapture) MimeMismatches() []MimeMismatch {
+	mismatches := make([]MimeMismatch, 0)
+	seen := make(map[string]bool)
+	for _, response := range nc.Responses {
+		if seen[response.URL] || !isMimeMismatch(response.DeclaredMimeType, response.DetectedMimeType) {
+			continue
+		}
+		seen[response.URL] = true
+		mismatches = append(mismatches, MimeMismatch{
+			URL:      response.URL,
+			Declared: response.DeclaredMimeType,
+			Detected: response.DetectedMimeType,
+		})
+	}
+	return mismatches
+}
+
+// SaveMimeMismatches writes responses with mismatched MIME types to mime_mismatches.json
+func (nc *NetworkCapture) SaveMimeMismatches() {
+	mismatches := nc.MimeMismatches()
+	if len(mismatches) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(mismatches, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode MIME mismatches", "error", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(nc.OutputDir, "mime_mismatches.json"), data, 0644); err != nil {
+		slog.Error("Failed to write MIME mismatches", "error", err)
+		return
+	}
+	slog.Info("Recorded MIME type mismatches", "count", len(mismatches))
+}
@@ -0,0 +1,95 @@
+package main
+
+import "testing"
+
+func TestDetectMimeType(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		url  string
+		want string
+	}{
+		{"html", "<!DOCTYPE html><html><body></body></html>", "https://example.com/", "text/html"},
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", "https://example.com/logo", "image/png"},
+		{"wasm", "\x00asm\x01\x00\x00\x00", "https://example.com/app", "application/wasm"},
+		{"woff2", "wOF2\x00\x01\x00\x00", "https://example.com/font", "font/woff2"},
+		{"json", `{"items": [1, 2, 3]}`, "https://example.com/api", "application/json"},
+		{"source map", `{"version":3,"sources":["a.js"],"mappings":"AAAA"}`, "https://example.com/app.js.map", "application/json"},
+		{"javascript", "(function(){ window.app = {}; })();", "https://example.com/bundle", "application/javascript"},
+		{"css by extension", "body { color: red; }", "https://example.com/site.css", "text/css"},
+		{"prose", "Hello there, this is plain text.", "https://example.com/readme", "text/plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectMimeType([]byte(tt.body), tt.url); got != tt.want {
+				t.Errorf("detectMimeType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveMimeType(t *testing.T) {
+	tests := []struct {
+		declared string
+		detected string
+		want     string
+	}{
+		{"text/html; charset=utf-8", "text/html", "text/html"},
+		{"application/octet-stream", "image/png", "image/png"},
+		{"", "application/javascript", "application/javascript"},
+		{"text/css", "text/plain", "text/css"},
+	}
+	for _, tt := range tests {
+		if got := resolveMimeType(tt.declared, tt.detected); got != tt.want {
+			t.Errorf("resolveMimeType(%q, %q) = %q, want %q", tt.declared, tt.detected, got, tt.want)
+		}
+	}
+}
+
+func TestIsMimeMismatch(t *testing.T) {
+	tests := []struct {
+		declared string
+		detected string
+		want     bool
+	}{
+		{"text/html", "application/json", true},
+		{"text/javascript", "application/javascript", false},
+		{"application/ld+json", "application/json", false},
+		{"image/svg+xml", "application/xml", false},
+		{"", "image/png", false},
+		{"text/css", "text/plain", false},
+	}
+	for _, tt := range tests {
+		if got := isMimeMismatch(tt.declared, tt.detected); got != tt.want {
+			t.Errorf("isMimeMismatch(%q, %q) = %v, want %v", tt.declared, tt.detected, got, tt.want)
+		}
+	}
+}
+
+func TestGetFileExtension(t *testing.T) {
+	tests := []struct {
+		mimeType string
+		body     string
+		want     string
+	}{
+		{"image/svg+xml", "<svg></svg>", ".svg"},
+		{"application/xml", "<feed></feed>", ".xml"},
+		{"text/html; charset=utf-8", "<html></html>", ".html"},
+		{"application/javascript", "", ".js"},
+		{"", "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", ".png"},
+		{"application/octet-stream", "<!DOCTYPE html><html></html>", ".html"},
+		{"", "", ".txt"},
+		{"application/json", "", ".json"},
+		{"application/x-javascript", "", ".js"},
+		{"text/css", "", ".css"},
+		{"image/jpeg", "", ".jpg"},
+		{"font/woff2", "", ".woff2"},
+		{"application/wasm", "", ".wasm"},
+		{"", "just some words", ".txt"},
+	}
+	for _, tt := range tests {
+		if got := getFileExtension(tt.mimeType, []byte(tt.body)); got != tt.want {
+			t.Errorf("getFileExtension(%q) = %q, want %q", tt.mimeType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// maxMirrorSegment is the longest path segment written before hashing
+const maxMirrorSegment = 100
+
+// mirrorUnsafeChars matches characters that are not safe in file names on common platforms
+var mirrorUnsafeChars = regexp.MustCompile(`[<>:"\\|?*\x00-\x1f]`)
+
+// cssURLPattern matches url(...) references and @import strings in stylesheets
+var cssURLPattern = regexp.MustCompile(`url\(\s*(['"]?)([^'")]+)(['"]?)\s*\)|@import\s+(['"])([^'"]+)(['"])`)
+
+// mirrorLinkAttributes lists the attributes rewritten to local paths, by tag
+var mirrorLinkAttributes = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"script": {"src"},
+	"img":    {"src"},
+	"iframe": {"src"},
+	"frame":  {"src"},
+	"source": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"embed":  {"src"},
+	"form":   {"action"},
+}
+
+// shortHash returns a stable 8 character hash of s
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:4])
+}
+
+// mirrorPath maps a URL to a relative file path that mirrors the site's
+// directory structure, e.g. https://example.com/docs/ -> example.com/docs/index.html.
+// Query strings and overly long names become stable hashed suffixes.
+func mirrorPath(rawURL, mimeType string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return filepath.Join("_invalid", shortHash(rawURL)+".bin")
+	}
+
+	host := sanitizeMirrorSegment(strings.ToLower(parsedURL.Host))
+	segments := strings.Split(strings.TrimPrefix(parsedURL.Path, "/"), "/")
+	isHTML := strings.Contains(strings.ToLower(mimeType), "html")
+
+	// Directories and extensionless HTML pages are stored as index files
+	name := segments[len(segments)-1]
+	dirs := segments[:len(segments)-1]
+	ext := path.Ext(name)
+	if name == "" || (isHTML && ext == "") {
+		if name != "" {
+			dirs = append(dirs, name)
+		}
+		name = "index"
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+	switch {
+	case ext == "":
+		ext = getFileExtension(mimeType, nil)
+	case isHTML && ext != ".html" && ext != ".htm":
+		// Keep pages such as page.php browsable offline
+		base = name
+		ext = ".html"
+	}
+
+	if parsedURL.RawQuery != "" {
+		base += "_" + shortHash(parsedURL.RawQuery)
+	}
+
+	parts := []string{host}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		parts = append(parts, sanitizeMirrorSegment(dir))
+	}
+	parts = append(parts, sanitizeMirrorSegment(base)+sanitizeMirrorSegment(ext))
+	return filepath.Join(parts...)
+}
+
+// hashSuffixedPath adds a stable hash of a URL to a file path before its
+// extension, e.g. docs/index.html -> docs/index_1a2b3c4d.html
+func hashSuffixedPath(localPath, rawURL string) string {
+	ext := filepath.Ext(localPath)
+	return strings.TrimSuffix(localPath, ext) + "_" + shortHash(rawURL) + ext
+}
+
+// sanitizeMirrorSegment makes a path segment safe to use as a file name
+func sanitizeMirrorSegment(segment string) string {
+	if unescaped, err := url.PathUnescape(segment); err == nil {
+		segment = unescaped
+	}
+	segment = mirrorUnsafeChars.ReplaceAllString(segment, "_")
+	segment = strings.ReplaceAll(segment, "/", "_")
+	if segment == "." || segment == ".." {
+		return "_"
+	}
+	if len(segment) > maxMirrorSegment {
+		segment = segment[:maxMirrorSegment-9] + "_" + shortHash(segment)
+	}
+	return segment
+}
+
+// mirrorKey normalizes a URL for lookups in the mirror map
+func mirrorKey(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	parsedURL.Fragment = ""
+	return parsedURL.String()
+}
+
+// mirrorLocalPath returns the local path a URL is saved at, assigning it the
+// first time the URL is seen. URLs that map to a path already taken, such as
+// /docs and /docs/ or the http and https versions of a page, get a hashed suffix.
+func (nc *NetworkCapture) mirrorLocalPath(rawURL, mimeType string) string {
+	if nc.mirrorPaths == nil {
+		nc.mirrorPaths = make(map[string]string)
+		nc.mirrorOwners = make(map[string]string)
+	}
+	key := mirrorKey(rawURL)
+	if localPath, ok := nc.mirrorPaths[key]; ok {
+		return localPath
+	}
+	localPath := mirrorPath(rawURL, mimeType)
+	if owner, taken := nc.mirrorOwners[localPath]; taken {
+		localPath = hashSuffixedPath(localPath, key)
+		slog.Debug("Mirror path collision", "url", rawURL, "other_url", owner, "file", localPath)
+	}
+	nc.mirrorOwners[localPath] = key
+	nc.mirrorPaths[key] = localPath
+	return localPath
+}
+
+// SaveMirror writes the captured responses under host/path directories
+// and rewrites links in saved HTML and CSS to local relative paths
+func (nc *NetworkCapture) SaveMirror() {
+	slog.Debug("Saving mirror", "count", len(nc.Responses))
+
+	// Map every captured URL to its local path first so links can be rewritten
+	for _, response := range nc.Responses {
+		nc.mirrorLocalPath(response.URL, response.MimeType)
+	}
+	localPaths := nc.mirrorPaths
+
+	savedCount := 0
+	written := make(map[string]bool)
+	for _, response := range nc.Responses {
+		localPath := localPaths[mirrorKey(response.URL)]
+		if written[localPath] || len(response.Body) == 0 {
+			continue
+		}
+		written[localPath] = true
+
+		body := response.Body
+		mimeType := strings.ToLower(response.MimeType)
+		switch {
+		case strings.Contains(mimeType, "html"):
+			body = rewriteHTMLLinks(body, response.URL, localPath, localPaths)
+		case strings.Contains(mimeType, "css"):
+			body = rewriteCSSLinks(body, response.URL, localPath, localPaths)
+		}
+
+		fullPath := filepath.Join(nc.OutputDir, localPath)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			slog.Error("Failed to create mirror directory", "url", response.URL, "error", err)
+			continue
+		}
+		if err := os.WriteFile(fullPath, body, 0644); err != nil {
+			slog.Error("Failed to write mirror file", "url", response.URL, "error", err)
+			continue
+		}
+		savedCount++
+	}
+
+	slog.Info("Mirrored files", "count", savedCount)
+}
+
+// localLink returns the link to use for ref in a file saved at fromPath.
+// Captured URLs become relative paths; anything else becomes an absolute URL.
+func localLink(ref, baseURL, fromPath string, localPaths map[string]string) string {
+	trimmed := strings.TrimSpace(ref)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "data:") ||
+		strings.HasPrefix(trimmed, "javascript:") || strings.HasPrefix(trimmed, "mailto:") {
+		return ref
+	}
+
+	absolute := resolveURL(trimmed, baseURL)
+	target, ok := localPaths[mirrorKey(absolute)]
+	if !ok {
+		return absolute
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(fromPath), target)
+	if err != nil {
+		return absolute
+	}
+	rel = filepath.ToSlash(rel)
+	if parsedURL, err := url.Parse(absolute); err == nil && parsedURL.Fragment != "" {
+		rel += "#" + parsedURL.Fragment
+	}
+	return rel
+}
+
+// rewriteHTMLLinks rewrites link attributes in an HTML document to local paths
+func rewriteHTMLLinks(body []byte, pageURL, fromPath string, localPaths map[string]string) []byte {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+
+	var rewrite func(*html.Node)
+	rewrite = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			for i, attr := range n.Attr {
+				for _, key := range mirrorLinkAttributes[n.Data] {
+					if attr.Key == key {
+						n.Attr[i].Val = localLink(attr.Val, pageURL, fromPath, localPaths)
+					}
+				}
+				if attr.Key == "style" {
+					n.Attr[i].Val = string(rewriteCSSLinks([]byte(attr.Val), pageURL, fromPath, localPaths))
+				}
+			}
+			if n.Data == "style" && n.FirstChild != nil && n.FirstChild.Type == html.TextNode {
+				n.FirstChild.Data = string(rewriteCSSLinks([]byte(n.FirstChild.Data), pageURL, fromPath, localPaths))
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			rewrite(c)
+		}
+	}
+	rewrite(doc)
+
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return body
+	}
+	return buf.Bytes()
+}
+
+// rewriteCSSLinks rewrites url(...) and @import references in a stylesheet to local paths
+func rewriteCSSLinks(body []byte, cssURL, fromPath string, localPaths map[string]string) []byte {
+	return cssURLPattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		groups := cssURLPattern.FindSubmatch(match)
+		if len(groups[2]) > 0 {
+			link := localLink(string(groups[2]), cssURL, fromPath, localPaths)
+			return []byte("url(" + string(groups[1]) + link + string(groups[3]) + ")")
+		}
+		link := localLink(string(groups[5]), cssURL, fromPath, localPaths)
+		return []byte("@import " + string(groups[4]) + link + string(groups[6]))
+	})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveMirrorPathCollisions(t *testing.T) {
+	outputDir := t.TempDir()
+	capture := &NetworkCapture{
+		OutputDir: outputDir,
+		Responses: []ResponseData{
+			NewResponseData("https://example.com/docs/", []byte(`<html><a href="http://example.com/docs">other</a></html>`), "text/html"),
+			NewResponseData("http://example.com/docs", []byte(`<html><p>http docs</p></html>`), "text/html"),
+		},
+	}
+	capture.SaveMirror()
+
+	first, err := os.ReadFile(filepath.Join(outputDir, "example.com", "docs", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffixed := hashSuffixedPath(filepath.Join("example.com", "docs", "index.html"), "http://example.com/docs")
+	second, err := os.ReadFile(filepath.Join(outputDir, suffixed))
+	if err != nil {
+		t.Fatalf("colliding URL was not saved under a suffixed path: %v", err)
+	}
+	if !strings.Contains(string(second), "http docs") {
+		t.Errorf("suffixed file = %q, want the http page", second)
+	}
+	if !strings.Contains(string(first), `href="`+filepath.Base(suffixed)+`"`) {
+		t.Errorf("link to the colliding URL was not rewritten to its own file: %s", first)
+	}
+}
+
+func TestPageCapturePathFollowsMirrorCollisions(t *testing.T) {
+	capture := &NetworkCapture{Mirror: true}
+	capture.addResponse(NewResponseData("https://example.com/docs/", []byte("<html></html>"), "text/html"))
+	capture.addResponse(NewResponseData("http://example.com/docs", []byte("<html></html>"), "text/html"))
+
+	first := capture.pageCapturePath("https://example.com/docs/", ".png")
+	if want := filepath.Join("example.com", "docs", "index.png"); first != want {
+		t.Errorf("capture path = %q, want %q", first, want)
+	}
+	suffixed := hashSuffixedPath(filepath.Join("example.com", "docs", "index.html"), "http://example.com/docs")
+	second := capture.pageCapturePath("http://example.com/docs", ".png")
+	if want := strings.TrimSuffix(suffixed, ".html") + ".png"; second != want {
+		t.Errorf("capture path of the colliding URL = %q, want %q next to its page", second, want)
+	}
+
+	capture = &NetworkCapture{}
+	capture.pageCapturePath("https://example.com/docs/", ".pdf")
+	if got := capture.pageCapturePath("http://example.com/docs", ".pdf"); !strings.HasPrefix(got, captureDir) || got == filepath.Join(captureDir, "example.com", "docs", "index.pdf") {
+		t.Errorf("capture path outside mirror mode = %q, want its own file under %s", got, captureDir)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Progress update intervals for terminals and for plain log output
+const (
+	progressRefresh     = time.Second
+	progressLogInterval = 10 * time.Second
+)
+
+// ProgressDisplay keeps a live status line at the bottom of a terminal.
+// Log output written through it is printed above the status line.
+type ProgressDisplay struct {
+	mu      sync.Mutex
+	out     io.Writer
+	metrics *Metrics
+	line    string
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewProgressDisplay creates a status line on out that reports metrics
+func NewProgressDisplay(out io.Writer, metrics *Metrics) *ProgressDisplay {
+	return &ProgressDisplay{out: out, metrics: metrics}
+}
+
+// Write prints log output above the status line
+func (p *ProgressDisplay) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.line != "" {
+		fmt.Fprint(p.out, "\r\033[K")
+	}
+	n, err := p.out.Write(b)
+	if p.line != "" {
+		fmt.Fprint(p.out, p.line)
+	}
+	return n, err
+}
+
+// Start redraws the status line every interval until Stop is called
+func (p *ProgressDisplay) Start(interval time.Duration) {
+	p.stop = make(chan struct{})
+	p.done = make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.redraw()
+			select {
+			case <-ticker.C:
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing and clears the status line
+func (p *ProgressDisplay) Stop() {
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	<-p.done
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.line != "" {
+		fmt.Fprint(p.out, "\r\033[K")
+		p.line = ""
+	}
+}
+
+func (p *ProgressDisplay) redraw() {
+	line := progressLine(p.metrics.Snapshot())
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.line = line
+	fmt.Fprint(p.out, "\r\033[K"+line)
+}
+
+// progressLine formats the crawl counters as a one-line status
+func progressLine(s MetricsSnapshot) string {
+	rate := pageRate(s)
+	line := fmt.Sprintf("%d pages | %d queued | %.2f pages/s | %d errors | %s elapsed",
+		s.Pages, s.QueueDepth, rate, s.Failures, s.Elapsed.Round(time.Second))
+	if eta, ok := progressETA(s); ok {
+		line += fmt.Sprintf(" | ETA %s", eta)
+	}
+	return line
+}
+
+// pageRate returns the pages crawled per second so far
+func pageRate(s MetricsSnapshot) float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Pages) / s.Elapsed.Seconds()
+}
+
+// progressETA estimates the time left to drain the frontier at the current rate.
+// The estimate grows as pages add links, so it is a lower bound.
+func progressETA(s MetricsSnapshot) (time.Duration, bool) {
+	rate := pageRate(s)
+	if rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(s.QueueDepth) / rate * float64(time.Second)).Round(time.Second), true
+}
+
+// logProgress logs the crawl counters every interval until stop is closed,
+// for output that is not a terminal
+func logProgress(metrics *Metrics, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s := metrics.Snapshot()
+			args := []interface{}{"pages", s.Pages, "queued", s.QueueDepth, "rate", fmt.Sprintf("%.2f", pageRate(s)), "errors", s.Failures, "elapsed", s.Elapsed.Round(time.Second)}
+			if eta, ok := progressETA(s); ok {
+				args = append(args, "eta", eta)
+			}
+			slog.Info("Progress", args...)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// isTerminal reports whether a file is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// URL resolution policies
+const (
+	ResolveStandard = "standard"
+	ResolveFallback = "fallback"
+	ResolveProbe    = "probe"
+)
+
+// How a link's URL was obtained
+const (
+	ResolutionLiteral     = "literal"
+	ResolutionStandard    = "standard"
+	ResolutionSpeculative = "speculative"
+)
+
+// probeTimeout bounds a single existence check
+const probeTimeout = 10 * time.Second
+
+// ResolvedURL is one absolute URL for an href, and how it was obtained
+type ResolvedURL struct {
+	URL        string
+	Resolution string
+}
+
+// URLResolver turns hrefs into absolute URLs under a resolution policy.
+// Standard resolves against the page URL only, fallback also guesses the
+// root-relative alternative for relative paths, and probe keeps that guess
+// only when the URL exists. It is safe for concurrent use; a nil resolver
+// uses the standard policy.
+type URLResolver struct {
+	policy string
+	probe  func(url string) bool
+
+	mu     sync.Mutex
+	probed map[string]bool
+}
+
+// NewURLResolver creates a resolver for a policy. Probes are HEAD requests
+// carrying the custom headers scoped to the probed host, made through the
+// fetcher's proxy and cookie jar.
+func NewURLResolver(policy string, headers []CustomHeader, fetcher *ResourceFetcher) (*URLResolver, error) {
+	switch policy {
+	case ResolveStandard, ResolveFallback, ResolveProbe:
+	default:
+		return nil, fmt.Errorf("unknown resolve policy %q (expected standard, fallback or probe)", policy)
+	}
+	return &URLResolver{
+		policy: policy,
+		probe:  func(url string) bool { return urlExists(fetcher.probeClient(), url, headers) },
+		probed: make(map[string]bool),
+	}, nil
+}
+
+// Resolve returns the absolute URLs an href may refer to
+func (r *URLResolver) Resolve(href, baseURL string) []ResolvedURL {
+	candidates := resolveURLWithFallback(href, baseURL)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	if strings.HasPrefix(href, "http") {
+		return []ResolvedURL{{URL: candidates[0], Resolution: ResolutionLiteral}}
+	}
+	resolved := []ResolvedURL{{URL: candidates[0], Resolution: ResolutionStandard}}
+	if r == nil || r.policy == ResolveStandard {
+		return resolved
+	}
+
+	for _, candidate := range candidates[1:] {
+		if r.policy == ResolveProbe && !r.exists(candidate) {
+			continue
+		}
+		resolved = append(resolved, ResolvedURL{URL: candidate, Resolution: ResolutionSpeculative})
+	}
+	return resolved
+}
+
+// exists probes a URL once and caches the answer
+func (r *URLResolver) exists(url string) bool {
+	r.mu.Lock()
+	found, ok := r.probed[url]
+	r.mu.Unlock()
+	if ok {
+		return found
+	}
+
+	found = r.probe(url)
+	r.mu.Lock()
+	r.probed[url] = found
+	r.mu.Unlock()
+	return found
+}
+
+// probeClient returns a client for existence probes. It shares the
+// fetcher's transport and cookie jar, but does not follow redirects, so a
+// guess that redirects to a login or catch-all page does not count.
+func (f *ResourceFetcher) probeClient() *http.Client {
+	return &http.Client{
+		Transport: f.Client.Transport,
+		Jar:       f.Client.Jar,
+		Timeout:   probeTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// urlExists reports whether a URL answers with a 2xx status.
+// Servers that reject HEAD are asked again with GET.
+func urlExists(client *http.Client, url string, headers []CustomHeader) bool {
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			return false
+		}
+		for key, value := range customHeadersFor(headers, url) {
+			req.Header.Set(key, value)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return false
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+		return resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+	}
+	return false
+}
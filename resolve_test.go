@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestURLResolverPolicies(t *testing.T) {
+	base := "https://example.com/docs/page.html"
+	standard := []ResolvedURL{{URL: "https://example.com/docs/js/app.js", Resolution: ResolutionStandard}}
+	speculative := ResolvedURL{URL: "https://example.com/js/app.js", Resolution: ResolutionSpeculative}
+
+	tests := []struct {
+		policy string
+		exists bool
+		want   []ResolvedURL
+	}{
+		{ResolveStandard, true, standard},
+		{ResolveFallback, false, append(standard[:1:1], speculative)},
+		{ResolveProbe, true, append(standard[:1:1], speculative)},
+		{ResolveProbe, false, standard},
+	}
+	for _, tt := range tests {
+		resolver, err := NewURLResolver(tt.policy, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exists := tt.exists
+		resolver.probe = func(string) bool { return exists }
+
+		if got := resolver.Resolve("js/app.js", base); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s (exists %v): Resolve() = %v, want %v", tt.policy, tt.exists, got, tt.want)
+		}
+	}
+}
+
+func TestURLResolverLiteral(t *testing.T) {
+	resolver, err := NewURLResolver(ResolveFallback, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := resolver.Resolve("https://cdn.example.com/lib.js", "https://example.com/a/b.html")
+	want := []ResolvedURL{{URL: "https://cdn.example.com/lib.js", Resolution: ResolutionLiteral}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Resolve() = %v, want %v", got, want)
+	}
+}
+
+func TestNewURLResolverRejectsUnknownPolicy(t *testing.T) {
+	if _, err := NewURLResolver("guess", nil, nil); err == nil {
+		t.Error("NewURLResolver(\"guess\") succeeded, want an error")
+	}
+}
+
+func TestURLResolverProbe(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/js/app.js":
+			w.WriteHeader(http.StatusOK)
+		case "/img/logo.png":
+			// Some servers only answer GET
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/admin/panel.html":
+			// Guesses that redirect to a login page do not exist
+			http.Redirect(w, r, "/js/app.js", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	fetcher, err := NewResourceFetcher(FetchHybrid, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := NewURLResolver(ResolveProbe, []CustomHeader{{Name: "Authorization", Value: "Bearer token", Hosts: []string{"127.0.0.1"}}}, fetcher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := server.URL + "/docs/page.html"
+	for _, tt := range []struct {
+		href string
+		want int
+	}{
+		{"js/app.js", 2},
+		{"img/logo.png", 2},
+		{"css/missing.css", 1},
+		{"admin/panel.html", 1},
+	} {
+		if got := resolver.Resolve(tt.href, base); len(got) != tt.want {
+			t.Errorf("Resolve(%q) = %v, want %d URLs", tt.href, got, tt.want)
+		}
+	}
+
+	// Probes are cached
+	before := atomic.LoadInt32(&requests)
+	resolver.Resolve("js/app.js", base)
+	if after := atomic.LoadInt32(&requests); after != before {
+		t.Errorf("repeated Resolve() made %d more requests", after-before)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SecretRule is a named regular expression matched against response bodies
+type SecretRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+
+	re *regexp.Regexp
+}
+
+// SecretFinding records a rule match in a captured response
+type SecretFinding struct {
+	URL    string `json:"url"`
+	Rule   string `json:"rule"`
+	Offset int    `json:"offset"`
+	Match  string `json:"match"`
+}
+
+// defaultSecretRules are the built-in rules used by the secret scanner
+var defaultSecretRules = []SecretRule{
+	{Name: "aws-access-key-id", Pattern: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "aws-secret-access-key", Pattern: `(?i)aws.{0,20}(secret|key).{0,20}['"][0-9a-zA-Z/+]{40}['"]`},
+	{Name: "google-api-key", Pattern: `\bAIza[0-9A-Za-z_\-]{35}\b`},
+	{Name: "github-token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36,}\b`},
+	{Name: "slack-token", Pattern: `\bxox[abposr]-[0-9A-Za-z-]{10,}\b`},
+	{Name: "generic-api-key", Pattern: `(?i)\b(api[_-]?key|api[_-]?secret|access[_-]?token|client[_-]?secret)\b['"]?\s*[:=]\s*['"][A-Za-z0-9_\-]{16,}['"]`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`},
+	{Name: "private-key", Pattern: `-----BEGIN ((RSA|EC|DSA|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`},
+	{Name: "email", Pattern: `\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`},
+	{Name: "internal-ip", Pattern: `\b(10\.\d{1,3}\.\d{1,3}\.\d{1,3}|172\.(1[6-9]|2\d|3[01])\.\d{1,3}\.\d{1,3}|192\.168\.\d{1,3}\.\d{1,3})\b`},
+}
+
+// redactKeep is the number of characters kept at each end of a redacted match
+const redactKeep = 4
+
+// SecretScanner matches a rule set against captured response bodies.
+// The same match at the same offset of a URL is reported once.
+type SecretScanner struct {
+	Rules    []SecretRule
+	Findings []SecretFinding
+
+	seen map[string]bool
+}
+
+// NewSecretScanner creates a scanner with the built-in rules plus any
+// custom rules loaded from rulesFile, a JSON array of {"name", "pattern"}
+func NewSecretScanner(rulesFile string) (*SecretScanner, error) {
+	rules := append([]SecretRule(nil), defaultSecretRules...)
+
+	if rulesFile != "" {
+		data, err := os.ReadFile(rulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read secret rules: %w", err)
+		}
+		var custom []SecretRule
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("failed to parse secret rules %s: %w", rulesFile, err)
+		}
+		rules = append(rules, custom...)
+	}
+
+	for i := range rules {
+		if rules[i].Name == "" || rules[i].Pattern == "" {
+			return nil, fmt.Errorf("secret rule %d needs both a name and a pattern", i+1)
+		}
+		re, err := regexp.Compile(rules[i].Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for secret rule %q: %w", rules[i].Name, err)
+		}
+		rules[i].re = re
+	}
+
+	return &SecretScanner{Rules: rules, Findings: make([]SecretFinding, 0), seen: make(map[string]bool)}, nil
+}
+
+// Scan runs every rule over body and records the new matches, redacted
+func (s *SecretScanner) Scan(urlStr string, body string) int {
+	found := 0
+	for _, rule := range s.Rules {
+		for _, loc := range rule.re.FindAllStringIndex(body, -1) {
+			key := fmt.Sprintf("%s\x00%s\x00%d", urlStr, rule.Name, loc[0])
+			if s.seen[key] {
+				continue
+			}
+			s.seen[key] = true
+			s.Findings = append(s.Findings, SecretFinding{
+				URL:    urlStr,
+				Rule:   rule.Name,
+				Offset: loc[0],
+				Match:  redactSecret(body[loc[0]:loc[1]]),
+			})
+			found++
+		}
+	}
+	return found
+}
+
+// redactSecret masks the middle of a match, keeping a few characters at
+// each end so findings can be told apart. Short matches are masked entirely.
+func redactSecret(match string) string {
+	runes := []rune(match)
+	if len(runes) <= 3*redactKeep {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:redactKeep]) + strings.Repeat("*", len(runes)-2*redactKeep) + string(runes[len(runes)-redactKeep:])
+}
+
+// SaveFindings writes the secret scanner findings to findings.json
+func (nc *NetworkCapture) SaveFindings() {
+	if nc.Secrets == nil {
+		return
+	}
+
+	data, err := json.MarshalIndent(nc.Secrets.Findings, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode findings", "error", err)
+		return
+	}
+	findingsFile := filepath.Join(nc.OutputDir, "findings.json")
+	if err := os.WriteFile(findingsFile, data, 0644); err != nil {
+		slog.Error("Failed to write findings file", "error", err)
+		return
+	}
+	slog.Info("Recorded secret findings", "count", len(nc.Secrets.Findings), "file", findingsFile)
+}
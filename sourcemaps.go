@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SourceSourceMap marks endpoints found in sources reconstructed from source maps
+const SourceSourceMap = "sourcemap"
+
+// sourcesDir is the output directory for reconstructed sources
+const sourcesDir = "sources"
+
+// sourceMappingPattern matches the //# sourceMappingURL= comment of a script
+// or the /*# sourceMappingURL= */ comment of a stylesheet
+var sourceMappingPattern = regexp.MustCompile(`(?m)^[ \t]*(?://|/\*)[#@][ \t]*sourceMappingURL=([^\s*]+)`)
+
+// sourceEndpointPattern matches string literals holding a root-relative path
+// or an absolute URL, e.g. fetch("/api/users") or "https://api.example.com/v1"
+var sourceEndpointPattern = regexp.MustCompile("[\"'`]((?:https?:)?/[^\"'`\\s<>]*)[\"'`]")
+
+// SourceMap is the part of a source map needed to rebuild the original sources
+type SourceMap struct {
+	Version        int       `json:"version"`
+	SourceRoot     string    `json:"sourceRoot"`
+	Sources        []string  `json:"sources"`
+	SourcesContent []*string `json:"sourcesContent"`
+}
+
+// SourceFile is an original source reconstructed from a source map
+type SourceFile struct {
+	MapURL  string `json:"map_url"`
+	Source  string `json:"source"`
+	Path    string `json:"path"`
+	Content string `json:"-"`
+}
+
+// isSourceMapped reports whether a MIME type may reference a source map
+func isSourceMapped(mimeType string) bool {
+	mimeType = canonicalMimeType(mimeType)
+	return mimeType == "application/javascript" || mimeType == "text/css"
+}
+
+// sourceMapURL returns the source map a script or stylesheet references,
+// resolved against its URL. The last reference wins, as in browsers.
+func sourceMapURL(body, resourceURL string) string {
+	matches := sourceMappingPattern.FindAllStringSubmatch(body, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	ref := matches[len(matches)-1][1]
+	if strings.HasPrefix(ref, "data:") {
+		return ref
+	}
+	return resolveURL(ref, resourceURL)
+}
+
+// decodeDataSourceMap decodes a source map inlined as a data: URL
+func decodeDataSourceMap(dataURL string) ([]byte, error) {
+	comma := strings.Index(dataURL, ",")
+	if comma < 0 {
+		return nil, fmt.Errorf("malformed data URL")
+	}
+	meta, data := dataURL[len("data:"):comma], dataURL[comma+1:]
+	if strings.HasSuffix(meta, ";base64") {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	decoded, err := url.PathUnescape(data)
+	return []byte(decoded), err
+}
+
+// sourcePath maps a source name such as webpack:///./src/app.js to a safe
+// relative path, keeping its directories but dropping any that climb out
+func sourcePath(source, sourceRoot string) string {
+	name := source
+	if sourceRoot != "" && !strings.Contains(source, "://") {
+		name = strings.TrimSuffix(sourceRoot, "/") + "/" + source
+	}
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	name = strings.SplitN(name, "?", 2)[0]
+
+	var parts []string
+	for _, segment := range strings.Split(name, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		parts = append(parts, sanitizeMirrorSegment(segment))
+	}
+	if len(parts) == 0 {
+		return "_unnamed"
+	}
+	return filepath.Join(parts...)
+}
+
+// extractSourceEndpoints finds root-relative paths and absolute URLs in
+// source code string literals, resolved against baseURL
+func extractSourceEndpoints(source, baseURL string) []string {
+	var endpoints []string
+	seen := make(map[string]bool)
+	for _, groups := range sourceEndpointPattern.FindAllStringSubmatch(source, -1) {
+		ref := groups[1]
+		// Template literal placeholders end the usable part of the path
+		if i := strings.Index(ref, "${"); i >= 0 {
+			ref = ref[:i]
+		}
+		// Skip "/" alone, protocol-relative and comment-like strings
+		if len(ref) < 2 || strings.HasPrefix(ref, "//") {
+			continue
+		}
+		endpoint := resolveURL(ref, baseURL)
+		if !seen[endpoint] {
+			seen[endpoint] = true
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints
+}
+
+// sourceMapDir returns the directory a source map's files are written to,
+// e.g. https://example.com/static/app.js.map -> example.com/static/app.js.map,
+// so that maps listing the same source names don't overwrite each other
+func sourceMapDir(mapURL string) string {
+	if parsedURL, err := url.Parse(mapURL); err != nil || parsedURL.Host == "" {
+		return filepath.Join("_inline", shortHash(mapURL))
+	}
+	return mirrorPath(mapURL, "")
+}
+
+// addSourceMap reconstructs the original sources of a source map, and adds
+// the endpoints and secrets found in them to the inventory and findings.
+// It returns the endpoints found, so they can be crawled.
+func (nc *NetworkCapture) addSourceMap(mapURL string, body []byte) []string {
+	var sourceMap SourceMap
+	if err := json.Unmarshal(body, &sourceMap); err != nil {
+		slog.Warn("Failed to parse source map", "url", mapURL, "error", err)
+		return nil
+	}
+
+	dir := sourceMapDir(mapURL)
+	var endpoints []string
+	seen := make(map[string]bool)
+	added := 0
+	for i, source := range sourceMap.Sources {
+		if i >= len(sourceMap.SourcesContent) || sourceMap.SourcesContent[i] == nil {
+			continue
+		}
+		content := *sourceMap.SourcesContent[i]
+		nc.Sources = append(nc.Sources, SourceFile{
+			MapURL:  mapURL,
+			Source:  source,
+			Path:    filepath.ToSlash(filepath.Join(sourcesDir, dir, sourcePath(source, sourceMap.SourceRoot))),
+			Content: content,
+		})
+		added++
+
+		for _, endpoint := range extractSourceEndpoints(content, mapURL) {
+			nc.Inventory.AddURL(http.MethodGet, endpoint, mapURL, SourceSourceMap)
+			if !seen[endpoint] {
+				seen[endpoint] = true
+				endpoints = append(endpoints, endpoint)
+			}
+		}
+		if nc.Secrets != nil {
+			if found := nc.Secrets.Scan(mapURL+"#"+source, content); found > 0 {
+				slog.Warn("Found potential secrets", "url", mapURL, "source", source, "count", found)
+			}
+		}
+	}
+	slog.Debug("Reconstructed sources", "url", mapURL, "count", added)
+	return endpoints
+}
+
+// SaveSources writes the reconstructed original sources under sources/,
+// with sources.json mapping each file to its source map
+func (nc *NetworkCapture) SaveSources() {
+	if len(nc.Sources) == 0 {
+		return
+	}
+
+	for _, file := range nc.Sources {
+		fullPath := filepath.Join(nc.OutputDir, filepath.FromSlash(file.Path))
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			slog.Error("Failed to create sources directory", "file", fullPath, "error", err)
+			continue
+		}
+		if err := os.WriteFile(fullPath, []byte(file.Content), 0644); err != nil {
+			slog.Error("Failed to write source", "file", fullPath, "error", err)
+			continue
+		}
+	}
+
+	data, err := json.MarshalIndent(nc.Sources, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode sources index", "error", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(nc.OutputDir, "sources.json"), data, 0644); err != nil {
+		slog.Error("Failed to write sources index", "error", err)
+		return
+	}
+	slog.Info("Saved reconstructed sources", "count", len(nc.Sources), "dir", filepath.Join(nc.OutputDir, sourcesDir))
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestSourceMapURL(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		url  string
+		want string
+	}{
+		{"script", "app();\n//# sourceMappingURL=app.js.map", "https://example.com/js/app.js", "https://example.com/js/app.js.map"},
+		{"stylesheet", "body{}\n/*# sourceMappingURL=site.css.map */", "https://example.com/css/site.css", "https://example.com/css/site.css.map"},
+		{"legacy marker", "app();\n//@ sourceMappingURL=/maps/app.map", "https://example.com/js/app.js", "https://example.com/maps/app.map"},
+		{"last wins", "//# sourceMappingURL=old.map\napp();\n//# sourceMappingURL=new.map", "https://example.com/app.js", "https://example.com/new.map"},
+		{"inline", "app();\n//# sourceMappingURL=data:application/json;base64,e30=", "https://example.com/app.js", "data:application/json;base64,e30="},
+		{"in a string", `var s = "//# sourceMappingURL=fake.map";`, "https://example.com/app.js", ""},
+		{"none", "app();", "https://example.com/app.js", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourceMapURL(tt.body, tt.url); got != tt.want {
+				t.Errorf("sourceMapURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDataSourceMap(t *testing.T) {
+	for _, dataURL := range []string{
+		"data:application/json;base64,eyJ2ZXJzaW9uIjozfQ==",
+		"data:application/json;charset=utf-8,%7B%22version%22%3A3%7D",
+	} {
+		got, err := decodeDataSourceMap(dataURL)
+		if err != nil || string(got) != `{"version":3}` {
+			t.Errorf("decodeDataSourceMap(%q) = %q, %v", dataURL, got, err)
+		}
+	}
+}
+
+func TestSourcePath(t *testing.T) {
+	tests := []struct {
+		source string
+		root   string
+		want   string
+	}{
+		{"webpack:///./src/app.js", "", "src/app.js"},
+		{"../../src/util.ts", "", "src/util.ts"},
+		{"components/Button.vue?a1b2", "/app", "app/components/Button.vue"},
+		{"webpack:///webpack/bootstrap", "", "webpack/bootstrap"},
+		{"../..", "", "_unnamed"},
+	}
+	for _, tt := range tests {
+		if got := sourcePath(tt.source, tt.root); got != tt.want {
+			t.Errorf("sourcePath(%q, %q) = %q, want %q", tt.source, tt.root, got, tt.want)
+		}
+	}
+}
+
+func TestExtractSourceEndpoints(t *testing.T) {
+	source := `// See https://docs.example.com
+	const API = "/api/v1";
+	fetch('/api/v1/users/' + id);
+	axios.get(` + "`/api/orders/${orderId}/items`" + `);
+	const cdn = "https://cdn.example.net/lib.js";
+	const proto = "//cdn.example.net/x.js";
+	const root = "/";
+	import x from "./local";`
+
+	got := extractSourceEndpoints(source, "https://example.com/static/app.js.map")
+	want := []string{
+		"https://example.com/api/v1",
+		"https://example.com/api/v1/users/",
+		"https://example.com/api/orders/",
+		"https://cdn.example.net/lib.js",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("extractSourceEndpoints() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestAddSourceMap(t *testing.T) {
+	capture := &NetworkCapture{Inventory: NewEndpointInventory("example.com")}
+	sourceMap := `{"version":3,"sources":["webpack:///./src/app.js","webpack:///./src/gone.js"],
+		"sourcesContent":["fetch(\"/api/session\", {method: \"POST\"});",null],"mappings":"AAAA"}`
+
+	got := capture.addSourceMap("https://example.com/app.js.map", []byte(sourceMap))
+	if want := []string{"https://example.com/api/session"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("addSourceMap() = %v, want %v", got, want)
+	}
+	if len(capture.Sources) != 1 {
+		t.Fatalf("reconstructed %d sources, want 1", len(capture.Sources))
+	}
+	if path := capture.Sources[0].Path; path != "sources/example.com/app.js.map/src/app.js" {
+		t.Errorf("source path = %q", path)
+	}
+
+	// Another map listing the same source name keeps its own copy
+	capture.addSourceMap("https://example.com/admin/app.js.map", []byte(sourceMap))
+	if path := capture.Sources[1].Path; path != "sources/example.com/admin/app.js.map/src/app.js" {
+		t.Errorf("second map source path = %q", path)
+	}
+
+	endpoints := capture.Inventory.Endpoints()
+	if len(endpoints) != 1 || endpoints[0].Method != http.MethodGet || endpoints[0].Path != "/api/session" {
+		t.Fatalf("endpoints = %+v, want GET /api/session", endpoints)
+	}
+	if !reflect.DeepEqual(endpoints[0].Sources, []string{SourceSourceMap}) {
+		t.Errorf("endpoint sources = %v, want [sourcemap]", endpoints[0].Sources)
+	}
+
+	if got := capture.addSourceMap("https://example.com/bad.map", []byte("not json")); got != nil || len(capture.Sources) != 2 {
+		t.Errorf("addSourceMap() on invalid JSON = %v, want nothing reconstructed", got)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Stream kinds
+const (
+	StreamWebSocket   = "websocket"
+	StreamEventSource = "eventsource"
+)
+
+// Message directions
+const (
+	DirectionSent     = "sent"
+	DirectionReceived = "received"
+)
+
+// defaultStreamFrames is the number of messages kept per page by default
+const defaultStreamFrames = 100
+
+// maxStreamPayload truncates long message payloads in the transcript
+const maxStreamPayload = 4096
+
+// StreamMessage is a WebSocket frame or a server-sent event
+type StreamMessage struct {
+	Direction string    `json:"direction"`
+	Opcode    int       `json:"opcode,omitempty"`
+	Event     string    `json:"event,omitempty"`
+	ID        string    `json:"id,omitempty"`
+	Data      string    `json:"data"`
+	Truncated bool      `json:"truncated,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Stream is a WebSocket or EventSource connection opened by a page,
+// with a transcript of its messages
+type Stream struct {
+	Kind     string          `json:"kind"`
+	URL      string          `json:"url"`
+	Page     string          `json:"page"`
+	Depth    int             `json:"depth"`
+	Status   int             `json:"status,omitempty"`
+	Opened   time.Time       `json:"opened"`
+	Closed   *time.Time      `json:"closed,omitempty"`
+	Sent     int             `json:"sent"`
+	Received int             `json:"received"`
+	Dropped  int             `json:"dropped,omitempty"`
+	Errors   []string        `json:"errors,omitempty"`
+	Messages []StreamMessage `json:"messages"`
+}
+
+// StreamRecorder records the WebSocket and EventSource connections pages
+// open. Transcripts are bounded per page; messages past the limit are only
+// counted. It is safe for concurrent use.
+type StreamRecorder struct {
+	limit int
+
+	mu      sync.Mutex
+	streams map[network.RequestID]*Stream
+	order   []*Stream
+	perPage map[string]int
+}
+
+// NewStreamRecorder creates a recorder keeping up to limit messages per page,
+// 0 for no limit
+func NewStreamRecorder(limit int) *StreamRecorder {
+	return &StreamRecorder{
+		limit:   limit,
+		streams: make(map[network.RequestID]*Stream),
+		perPage: make(map[string]int),
+	}
+}
+
+// open starts recording a stream for the page currently loading
+func (sr *StreamRecorder) open(requestID network.RequestID, kind, streamURL string, page *Request) *Stream {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	stream := &Stream{Kind: kind, URL: streamURL, Opened: time.Now(), Messages: make([]StreamMessage, 0)}
+	if page != nil {
+		stream.Page = page.URL
+		stream.Depth = page.Depth
+	}
+	sr.streams[requestID] = stream
+	sr.order = append(sr.order, stream)
+	return stream
+}
+
+// add appends a message to a stream's transcript
+func (sr *StreamRecorder) add(requestID network.RequestID, message StreamMessage) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	stream, ok := sr.streams[requestID]
+	if !ok {
+		return
+	}
+	if message.Direction == DirectionSent {
+		stream.Sent++
+	} else {
+		stream.Received++
+	}
+
+	if sr.limit > 0 && sr.perPage[stream.Page] >= sr.limit {
+		stream.Dropped++
+		return
+	}
+	sr.perPage[stream.Page]++
+	if len(message.Data) > maxStreamPayload {
+		message.Data = message.Data[:maxStreamPayload]
+		message.Truncated = true
+	}
+	message.Time = time.Now()
+	stream.Messages = append(stream.Messages, message)
+}
+
+// update changes a recorded stream under the lock
+func (sr *StreamRecorder) update(requestID network.RequestID, change func(*Stream)) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	if stream, ok := sr.streams[requestID]; ok {
+		change(stream)
+	}
+}
+
+// Streams returns the recorded streams in the order they were opened
+func (sr *StreamRecorder) Streams() []*Stream {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	return append([]*Stream(nil), sr.order...)
+}
+
+// listenForStreams records WebSocket frames and server-sent events
+func (nc *NetworkCapture) listenForStreams(ctx context.Context) {
+	sr := nc.Streams
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventWebSocketCreated:
+			stream := sr.open(ev.RequestID, StreamWebSocket, ev.URL, nc.Traffic.Page())
+			nc.Inventory.AddURL(http.MethodGet, ev.URL, stream.Page, SourceWebSocket)
+			slog.Debug("WebSocket opened", "url", ev.URL, "page", stream.Page)
+
+		case *network.EventWebSocketHandshakeResponseReceived:
+			if ev.Response != nil {
+				sr.update(ev.RequestID, func(s *Stream) { s.Status = int(ev.Response.Status) })
+			}
+
+		case *network.EventWebSocketFrameSent:
+			if ev.Response != nil {
+				sr.add(ev.RequestID, StreamMessage{Direction: DirectionSent, Opcode: int(ev.Response.Opcode), Data: ev.Response.PayloadData})
+			}
+
+		case *network.EventWebSocketFrameReceived:
+			if ev.Response != nil {
+				sr.add(ev.RequestID, StreamMessage{Direction: DirectionReceived, Opcode: int(ev.Response.Opcode), Data: ev.Response.PayloadData})
+			}
+
+		case *network.EventWebSocketFrameError:
+			sr.update(ev.RequestID, func(s *Stream) { s.Errors = append(s.Errors, ev.ErrorMessage) })
+
+		case *network.EventWebSocketClosed:
+			closed := time.Now()
+			sr.update(ev.RequestID, func(s *Stream) { s.Closed = &closed })
+
+		case *network.EventRequestWillBeSent:
+			if ev.Type == network.ResourceTypeEventSource && ev.Request != nil {
+				stream := sr.open(ev.RequestID, StreamEventSource, ev.Request.URL, nc.Traffic.Page())
+				nc.Inventory.AddURL(http.MethodGet, ev.Request.URL, stream.Page, SourceEventSource)
+				slog.Debug("EventSource opened", "url", ev.Request.URL, "page", stream.Page)
+			}
+
+		case *network.EventResponseReceived:
+			if ev.Type == network.ResourceTypeEventSource && ev.Response != nil {
+				sr.update(ev.RequestID, func(s *Stream) { s.Status = int(ev.Response.Status) })
+			}
+
+		case *network.EventEventSourceMessageReceived:
+			sr.add(ev.RequestID, StreamMessage{Direction: DirectionReceived, Event: ev.EventName, ID: ev.EventID, Data: ev.Data})
+		}
+	})
+}
+
+// SaveStreams writes the recorded WebSocket and EventSource transcripts to streams.json
+func (nc *NetworkCapture) SaveStreams() {
+	if nc.Streams == nil {
+		return
+	}
+	streams := nc.Streams.Streams()
+	if len(streams) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(streams, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode streams", "error", err)
+		return
+	}
+	streamsFile := filepath.Join(nc.OutputDir, "streams.json")
+	if err := os.WriteFile(streamsFile, data, 0644); err != nil {
+		slog.Error("Failed to write streams file", "error", err)
+		return
+	}
+	slog.Info("Saved WebSocket and EventSource streams", "count", len(streams), "file", streamsFile)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestStreamRecorderLimitsMessagesPerPage(t *testing.T) {
+	sr := NewStreamRecorder(3)
+	page := &Request{URL: "https://example.com/chat", Depth: 1}
+	sr.open("ws-1", StreamWebSocket, "wss://example.com/socket", page)
+	sr.open("sse-1", StreamEventSource, "https://example.com/events", page)
+
+	sr.add("ws-1", StreamMessage{Direction: DirectionSent, Opcode: 1, Data: "hello"})
+	sr.add("ws-1", StreamMessage{Direction: DirectionReceived, Opcode: 1, Data: strings.Repeat("x", maxStreamPayload+10)})
+	sr.add("sse-1", StreamMessage{Direction: DirectionReceived, Event: "tick", Data: "1"})
+	sr.add("sse-1", StreamMessage{Direction: DirectionReceived, Event: "tick", Data: "2"})
+	sr.add("unknown", StreamMessage{Direction: DirectionReceived, Data: "ignored"})
+
+	streams := sr.Streams()
+	if len(streams) != 2 {
+		t.Fatalf("recorded %d streams, want 2", len(streams))
+	}
+	ws, sse := streams[0], streams[1]
+	if ws.Page != page.URL || ws.Depth != 1 || ws.Sent != 1 || ws.Received != 1 || len(ws.Messages) != 2 {
+		t.Errorf("websocket stream = %+v", ws)
+	}
+	if long := ws.Messages[1]; len(long.Data) != maxStreamPayload || !long.Truncated {
+		t.Errorf("long payload kept %d bytes, truncated %v", len(long.Data), long.Truncated)
+	}
+	if sse.Received != 2 || len(sse.Messages) != 1 || sse.Dropped != 1 {
+		t.Errorf("eventsource stream received %d, kept %d, dropped %d; want 2, 1, 1", sse.Received, len(sse.Messages), sse.Dropped)
+	}
+
+	// Another page has its own budget
+	sr.open("ws-2", StreamWebSocket, "wss://example.com/socket", &Request{URL: "https://example.com/other"})
+	sr.add("ws-2", StreamMessage{Direction: DirectionReceived, Data: "fresh"})
+	if got := len(sr.Streams()[2].Messages); got != 1 {
+		t.Errorf("second page kept %d messages, want 1", got)
+	}
+}
+
+func TestStreamRecorderUpdate(t *testing.T) {
+	sr := NewStreamRecorder(0)
+	sr.open(network.RequestID("ws"), StreamWebSocket, "wss://example.com/socket", nil)
+	sr.update("ws", func(s *Stream) { s.Status = 101 })
+	sr.update("missing", func(s *Stream) { t.Error("update called for an unknown stream") })
+	if got := sr.Streams()[0].Status; got != 101 {
+		t.Errorf("status = %d, want 101", got)
+	}
+}
+
+func TestInventoryAcceptsWebSocketURLs(t *testing.T) {
+	inv := NewEndpointInventory("example.com")
+	inv.AddURL("GET", "wss://example.com/socket?room=1", "https://example.com/chat", SourceWebSocket)
+	inv.AddURL("GET", "ftp://example.com/file", "https://example.com/", SourceLink)
+
+	endpoints := inv.Endpoints()
+	if len(endpoints) != 1 || endpoints[0].Path != "/socket" || endpoints[0].Sources[0] != SourceWebSocket {
+		t.Errorf("endpoints = %+v, want the WebSocket endpoint only", endpoints)
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// slowestPageCount is how many of the slowest pages the summary lists
+const slowestPageCount = 10
+
+// PageTiming records how long a crawled page took to load
+type PageTiming struct {
+	URL      string        `json:"url"`
+	Depth    int           `json:"depth"`
+	Status   int           `json:"status,omitempty"`
+	Duration time.Duration `json:"-"`
+	Seconds  float64       `json:"seconds"`
+}
+
+// HostCount is an out-of-scope host and how often it was referenced
+type HostCount struct {
+	Host  string `json:"host"`
+	Count int    `json:"count"`
+}
+
+// CrawlStats collects figures for the end-of-crawl summary as pages are crawled
+type CrawlStats struct {
+	Started      time.Time
+	PagesByDepth map[int]int
+	StatusCodes  map[int]int
+	Retries      int
+	OutOfScope   map[string]int
+	Timings      []PageTiming
+}
+
+// CrawlSummary is the end-of-crawl report written to summary.json
+type CrawlSummary struct {
+	Target          string          `json:"target"`
+	StartedAt       time.Time       `json:"started_at"`
+	FinishedAt      time.Time       `json:"finished_at"`
+	Elapsed         string          `json:"elapsed"`
+	ElapsedSeconds  float64         `json:"elapsed_seconds"`
+	PagesCrawled    int             `json:"pages_crawled"`
+	PagesByDepth    map[int]int     `json:"pages_by_depth"`
+	StatusCodes     map[int]int     `json:"status_codes"`
+	MimeTypes       map[string]int  `json:"mime_types"`
+	Responses       int             `json:"responses"`
+	TotalBytes      int64           `json:"total_bytes"`
+	Retries         int             `json:"retries"`
+	Failures        []FailedRequest `json:"failures"`
+	OutOfScopeHosts []HostCount     `json:"out_of_scope_hosts"`
+	SlowestPages    []PageTiming    `json:"slowest_pages"`
+}
+
+// NewCrawlStats starts collecting crawl statistics
+func NewCrawlStats() *CrawlStats {
+	return &CrawlStats{
+		Started:      time.Now(),
+		PagesByDepth: make(map[int]int),
+		StatusCodes:  make(map[int]int),
+		OutOfScope:   make(map[string]int),
+	}
+}
+
+// AddPage records a page that loaded, its status and how long it took
+func (s *CrawlStats) AddPage(job *Request, status int, elapsed time.Duration) {
+	s.PagesByDepth[job.Depth]++
+	s.StatusCodes[status]++
+	s.Timings = append(s.Timings, PageTiming{
+		URL:      job.URL,
+		Depth:    job.Depth,
+		Status:   status,
+		Duration: elapsed,
+		Seconds:  elapsed.Seconds(),
+	})
+}
+
+// AddRetries records retries spent on a request
+func (s *CrawlStats) AddRetries(retries int) {
+	if retries > 0 {
+		s.Retries += retries
+	}
+}
+
+// AddOutOfScope counts a reference to a URL outside the crawl scope
+func (s *CrawlStats) AddOutOfScope(urlStr string) {
+	parsedURL, err := url.Parse(urlStr)
+	if err != nil || parsedURL.Host == "" {
+		return
+	}
+	s.OutOfScope[normalizeHost(parsedURL.Host)]++
+}
+
+// Summary builds the end-of-crawl report from the collected statistics
+func (nc *NetworkCapture) Summary(finished time.Time) CrawlSummary {
+	s := nc.Stats
+	elapsed := finished.Sub(s.Started)
+	summary := CrawlSummary{
+		Target:          nc.TargetHost,
+		StartedAt:       s.Started,
+		FinishedAt:      finished,
+		Elapsed:         elapsed.Round(time.Millisecond).String(),
+		ElapsedSeconds:  elapsed.Seconds(),
+		PagesCrawled:    len(s.Timings),
+		PagesByDepth:    s.PagesByDepth,
+		StatusCodes:     s.StatusCodes,
+		MimeTypes:       make(map[string]int),
+		Responses:       len(nc.Responses),
+		Retries:         s.Retries,
+		Failures:        nc.Failures,
+		OutOfScopeHosts: make([]HostCount, 0, len(s.OutOfScope)),
+	}
+	if summary.Failures == nil {
+		summary.Failures = make([]FailedRequest, 0)
+	}
+
+	for _, response := range nc.Responses {
+		mimeType := response.MimeType
+		if mimeType == "" {
+			mimeType = "unknown"
+		}
+		summary.MimeTypes[mimeType]++
+		summary.TotalBytes += int64(len(response.Body))
+	}
+
+	for host, count := range s.OutOfScope {
+		summary.OutOfScopeHosts = append(summary.OutOfScopeHosts, HostCount{Host: host, Count: count})
+	}
+	sort.Slice(summary.OutOfScopeHosts, func(i, j int) bool {
+		a, b := summary.OutOfScopeHosts[i], summary.OutOfScopeHosts[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Host < b.Host
+	})
+
+	summary.SlowestPages = append([]PageTiming{}, s.Timings...)
+	sort.SliceStable(summary.SlowestPages, func(i, j int) bool {
+		return summary.SlowestPages[i].Duration > summary.SlowestPages[j].Duration
+	})
+	if len(summary.SlowestPages) > slowestPageCount {
+		summary.SlowestPages = summary.SlowestPages[:slowestPageCount]
+	}
+	return summary
+}
+
+// SaveSummary writes summary.json and prints the summary as a table on
+// stderr, keeping stdout for the captured URLs
+func (nc *NetworkCapture) SaveSummary(stderr io.Writer) {
+	summary := nc.Summary(time.Now())
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode summary", "error", err)
+	} else if err := os.WriteFile(filepath.Join(nc.OutputDir, "summary.json"), data, 0644); err != nil {
+		slog.Error("Failed to write summary", "error", err)
+	}
+
+	if progressEnabled() {
+		printSummary(stderr, summary)
+	}
+}
+
+// printSummary renders a crawl summary as aligned tables
+func printSummary(out io.Writer, summary CrawlSummary) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "\nCrawl summary for %s\n", summary.Target)
+	fmt.Fprintf(w, "  Elapsed\t%s\n", summary.Elapsed)
+	fmt.Fprintf(w, "  Pages crawled\t%d\n", summary.PagesCrawled)
+	fmt.Fprintf(w, "  Responses saved\t%d\n", summary.Responses)
+	fmt.Fprintf(w, "  Total bytes\t%d\n", summary.TotalBytes)
+	fmt.Fprintf(w, "  Retries\t%d\n", summary.Retries)
+	fmt.Fprintf(w, "  Failures\t%d\n", len(summary.Failures))
+
+	if len(summary.PagesByDepth) > 0 {
+		fmt.Fprintf(w, "\n  Depth\tPages\n")
+		for _, depth := range sortedIntKeys(summary.PagesByDepth) {
+			fmt.Fprintf(w, "  %d\t%d\n", depth, summary.PagesByDepth[depth])
+		}
+	}
+
+	if len(summary.StatusCodes) > 0 {
+		fmt.Fprintf(w, "\n  Status\tPages\n")
+		for _, status := range sortedIntKeys(summary.StatusCodes) {
+			label := fmt.Sprint(status)
+			if status == 0 {
+				label = "unknown"
+			}
+			fmt.Fprintf(w, "  %s\t%d\n", label, summary.StatusCodes[status])
+		}
+	}
+
+	if len(summary.MimeTypes) > 0 {
+		mimeTypes := make([]string, 0, len(summary.MimeTypes))
+		for mimeType := range summary.MimeTypes {
+			mimeTypes = append(mimeTypes, mimeType)
+		}
+		sort.Strings(mimeTypes)
+		fmt.Fprintf(w, "\n  MIME type\tResponses\n")
+		for _, mimeType := range mimeTypes {
+			fmt.Fprintf(w, "  %s\t%d\n", mimeType, summary.MimeTypes[mimeType])
+		}
+	}
+
+	if len(summary.SlowestPages) > 0 {
+		fmt.Fprintf(w, "\n  Slowest pages\tSeconds\n")
+		for _, timing := range summary.SlowestPages {
+			fmt.Fprintf(w, "  %s\t%.2f\n", timing.URL, timing.Seconds)
+		}
+	}
+
+	if len(summary.Failures) > 0 {
+		fmt.Fprintf(w, "\n  Failed URL\tKind\tReason\n")
+		for _, failure := range summary.Failures {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", failure.URL, failure.Kind, failure.Reason)
+		}
+	}
+
+	if len(summary.OutOfScopeHosts) > 0 {
+		fmt.Fprintf(w, "\n  Out-of-scope host\tReferences\n")
+		for _, host := range summary.OutOfScopeHosts {
+			fmt.Fprintf(w, "  %s\t%d\n", host.Host, host.Count)
+		}
+	}
+}
+
+// sortedIntKeys returns the keys of a count map in ascending order
+func sortedIntKeys(counts map[int]int) []int {
+	keys := make([]int, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
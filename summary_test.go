@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCrawlSummaryAggregation(t *testing.T) {
+	stats := NewCrawlStats()
+	stats.Started = time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		status := 200
+		if i%4 == 3 {
+			status = 404
+		}
+		job := &Request{URL: fmt.Sprintf("https://example.com/page%d", i), Depth: i % 3}
+		stats.AddPage(job, status, time.Duration(i)*time.Second)
+	}
+	stats.AddPage(&Request{URL: "https://example.com/failed", Depth: 2}, 0, 30*time.Second)
+	stats.AddRetries(2)
+	stats.AddRetries(0)
+	stats.AddRetries(1)
+	for _, ref := range []string{
+		"https://cdn.other.net/a.js", "https://www.Other.net:8443/b", "https://cdn.other.net/c.css",
+		"https://ads.example.org/", "/relative", "://bad",
+	} {
+		stats.AddOutOfScope(ref)
+	}
+
+	nc := &NetworkCapture{
+		TargetHost: "example.com",
+		Stats:      stats,
+		Responses: []ResponseData{
+			{MimeType: "text/html", Body: []byte("<html></html>")},
+			{MimeType: "text/html", Body: []byte("<p>")},
+			{Body: []byte("??")},
+		},
+	}
+	summary := nc.Summary(stats.Started.Add(90 * time.Second))
+
+	if summary.PagesCrawled != 13 || summary.Elapsed != "1m30s" || summary.Retries != 3 {
+		t.Errorf("pages = %d, elapsed = %s, retries = %d", summary.PagesCrawled, summary.Elapsed, summary.Retries)
+	}
+	if want := map[int]int{0: 4, 1: 4, 2: 5}; !reflect.DeepEqual(summary.PagesByDepth, want) {
+		t.Errorf("pages by depth = %v, want %v", summary.PagesByDepth, want)
+	}
+	if want := map[int]int{200: 9, 404: 3, 0: 1}; !reflect.DeepEqual(summary.StatusCodes, want) {
+		t.Errorf("status codes = %v, want %v", summary.StatusCodes, want)
+	}
+	if want := map[string]int{"text/html": 2, "unknown": 1}; !reflect.DeepEqual(summary.MimeTypes, want) {
+		t.Errorf("MIME types = %v, want %v", summary.MimeTypes, want)
+	}
+	if summary.Responses != 3 || summary.TotalBytes != 18 {
+		t.Errorf("responses = %d, total bytes = %d", summary.Responses, summary.TotalBytes)
+	}
+	if summary.Failures == nil {
+		t.Error("failures are nil, want an empty list in summary.json")
+	}
+
+	wantHosts := []HostCount{{Host: "cdn.other.net", Count: 2}, {Host: "ads.example.org", Count: 1}, {Host: "other.net", Count: 1}}
+	if !reflect.DeepEqual(summary.OutOfScopeHosts, wantHosts) {
+		t.Errorf("out-of-scope hosts = %v, want %v", summary.OutOfScopeHosts, wantHosts)
+	}
+
+	if len(summary.SlowestPages) != slowestPageCount {
+		t.Fatalf("listed %d slowest pages, want %d", len(summary.SlowestPages), slowestPageCount)
+	}
+	slowest := summary.SlowestPages[0]
+	if slowest.URL != "https://example.com/failed" || slowest.Seconds != 30 {
+		t.Errorf("slowest page = %+v", slowest)
+	}
+	for i, timing := range summary.SlowestPages[1:] {
+		if want := fmt.Sprintf("https://example.com/page%d", 11-i); timing.URL != want {
+			t.Errorf("slowest page %d = %s, want %s", i+1, timing.URL, want)
+		}
+	}
+	if len(stats.Timings) != 13 || stats.Timings[0].URL != "https://example.com/page0" {
+		t.Error("building the summary reordered the collected timings")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultTemplateLimit caps the concrete URLs crawled per path template
+const defaultTemplateLimit = 10
+
+// Patterns for ID-like path segments, checked in order
+var segmentPatterns = []struct {
+	placeholder string
+	pattern     *regexp.Regexp
+}{
+	{"uuid", regexp.MustCompile(`^(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)},
+	{"date", regexp.MustCompile(`^\d{4}-(0[1-9]|1[0-2])-(0[1-9]|[12]\d|3[01])$`)},
+	{"id", regexp.MustCompile(`^\d+$`)},
+	{"hash", regexp.MustCompile(`^(?i)[0-9a-f]{16,}$`)},
+}
+
+// PathSegment is a path segment replaced by a placeholder in a template
+type PathSegment struct {
+	Name  string
+	Value string
+}
+
+// templatePath collapses ID-like segments of a URL path into placeholders
+// such as /users/{id}. Repeated placeholders are numbered: /a/{id}/b/{id2}.
+// It also returns the concrete values that were replaced.
+func templatePath(path string) (string, []PathSegment) {
+	segments := strings.Split(path, "/")
+	var replaced []PathSegment
+	counts := make(map[string]int)
+
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		for _, sp := range segmentPatterns {
+			if !sp.pattern.MatchString(segment) {
+				continue
+			}
+			counts[sp.placeholder]++
+			name := sp.placeholder
+			if counts[sp.placeholder] > 1 {
+				name = fmt.Sprintf("%s%d", sp.placeholder, counts[sp.placeholder])
+			}
+			replaced = append(replaced, PathSegment{Name: name, Value: segment})
+			segments[i] = "{" + name + "}"
+			break
+		}
+	}
+	return strings.Join(segments, "/"), replaced
+}
+
+// PathTemplate tracks the concrete URLs seen for one path template
+type PathTemplate struct {
+	Host      string   `json:"host"`
+	Template  string   `json:"template"`
+	Instances []string `json:"instances"`
+	Skipped   int      `json:"skipped"`
+
+	seen    map[string]bool
+	skipped map[string]bool
+}
+
+// PathTemplates caps how many concrete URLs are crawled per path template.
+// It is safe for concurrent use.
+type PathTemplates struct {
+	limit     int
+	mu        sync.Mutex
+	templates map[string]*PathTemplate
+}
+
+// NewPathTemplates creates a tracker allowing limit URLs per template, 0 for no limit
+func NewPathTemplates(limit int) *PathTemplates {
+	return &PathTemplates{
+		limit:     limit,
+		templates: make(map[string]*PathTemplate),
+	}
+}
+
+// Allow records a URL against its template and reports whether it may be crawled.
+// URLs without ID-like segments are always allowed.
+func (pt *PathTemplates) Allow(rawURL string) bool {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+	template, replaced := templatePath(parsedURL.EscapedPath())
+	if len(replaced) == 0 {
+		return true
+	}
+	host := strings.ToLower(parsedURL.Host)
+	key := host + template
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	entry, ok := pt.templates[key]
+	if !ok {
+		entry = &PathTemplate{Host: host, Template: template, seen: make(map[string]bool), skipped: make(map[string]bool)}
+		pt.templates[key] = entry
+	}
+	if entry.seen[rawURL] {
+		return true
+	}
+	if entry.skipped[rawURL] {
+		return false
+	}
+	if pt.limit > 0 && len(entry.Instances) >= pt.limit {
+		// Repeated links to a skipped URL count once
+		entry.skipped[rawURL] = true
+		entry.Skipped++
+		return false
+	}
+	entry.seen[rawURL] = true
+	entry.Instances = append(entry.Instances, rawURL)
+	return true
+}
+
+// Templates returns the templates seen so far, sorted by host and template
+func (pt *PathTemplates) Templates() []*PathTemplate {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	templates := make([]*PathTemplate, 0, len(pt.templates))
+	for _, entry := range pt.templates {
+		templates = append(templates, entry)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		if templates[i].Host != templates[j].Host {
+			return templates[i].Host < templates[j].Host
+		}
+		return templates[i].Template < templates[j].Template
+	})
+	return templates
+}
+
+// SaveTemplates writes the path templates to templates.json
+func (nc *NetworkCapture) SaveTemplates() {
+	templates := nc.Templates.Templates()
+	if len(templates) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode path templates", "error", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(nc.OutputDir, "templates.json"), data, 0644); err != nil {
+		slog.Error("Failed to write path templates", "error", err)
+		return
+	}
+
+	skipped := 0
+	for _, entry := range templates {
+		skipped += entry.Skipped
+	}
+	slog.Info("Saved path templates", "templates", len(templates), "skipped", skipped)
+}
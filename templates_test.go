@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestTemplatePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		want     string
+		replaced int
+	}{
+		{"/users/42", "/users/{id}", 1},
+		{"/users/42/posts/7", "/users/{id}/posts/{id2}", 2},
+		{"/orders/3f2504e0-4f89-11d3-9a0c-0305e82c3301", "/orders/{uuid}", 1},
+		{"/archive/2024-02-29/", "/archive/{date}/", 1},
+		{"/assets/9f86d081884c7d659a2feaa0c55ad015", "/assets/{hash}", 1},
+		{"/about/team", "/about/team", 0},
+		{"/v2/items", "/v2/items", 0},
+	}
+	for _, tt := range tests {
+		got, replaced := templatePath(tt.path)
+		if got != tt.want || len(replaced) != tt.replaced {
+			t.Errorf("templatePath(%q) = %q with %d replacements, want %q with %d", tt.path, got, len(replaced), tt.want, tt.replaced)
+		}
+	}
+}
+
+func TestPathTemplatesAllow(t *testing.T) {
+	pt := NewPathTemplates(2)
+	for _, u := range []string{"https://example.com/users/1", "https://example.com/users/2"} {
+		if !pt.Allow(u) {
+			t.Fatalf("Allow(%q) = false within the limit", u)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if pt.Allow("https://example.com/users/3") {
+			t.Error("Allow() = true beyond the template limit")
+		}
+	}
+	if !pt.Allow("https://example.com/users/1") {
+		t.Error("Allow() = false for a URL that was already allowed")
+	}
+	if !pt.Allow("https://example.com/about") {
+		t.Error("Allow() = false for a URL without ID-like segments")
+	}
+
+	templates := pt.Templates()
+	if len(templates) != 1 || templates[0].Template != "/users/{id}" || templates[0].Skipped != 1 {
+		t.Errorf("Templates() = %+v, want /users/{id} with one skipped URL", templates)
+	}
+}
@@ -0,0 +1,2 @@
+window.fixtureLoaded = true;
+//# sourceMappingURL=app.js.map
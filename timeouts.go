@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Default per-request timeouts
+const (
+	defaultCrawlTimeout    = 300 * time.Second
+	defaultPageTimeout     = 30 * time.Second
+	defaultResourceTimeout = 30 * time.Second
+	defaultScriptTimeout   = 10 * time.Second
+)
+
+// Failure kinds recorded in the output
+const (
+	FailurePage     = "page"
+	FailureResource = "resource"
+	FailureScript   = "script"
+	FailureCapture  = "capture"
+)
+
+// FailedRequest records a request that could not be completed
+type FailedRequest struct {
+	URL      string `json:"url"`
+	Source   string `json:"source,omitempty"`
+	Depth    int    `json:"depth"`
+	Kind     string `json:"kind"`
+	Reason   string `json:"reason"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
+}
+
+// runWithTimeout runs chromedp actions bounded by their own timeout.
+// A zero or negative timeout leaves only the parent context's deadline.
+func runWithTimeout(ctx context.Context, timeout time.Duration, actions ...chromedp.Action) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return chromedp.Run(ctx, actions...)
+}
+
+// runResponseWithTimeout runs a navigation bounded by its own timeout and
+// returns the response of the navigated document
+func runResponseWithTimeout(ctx context.Context, timeout time.Duration, action chromedp.Action) (*network.Response, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return chromedp.RunResponse(ctx, action)
+}
+
+// isTimeout reports whether err was caused by a deadline
+func isTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// failureReason describes err for the failures file
+func failureReason(err error, timeout time.Duration) string {
+	if isTimeout(err) && timeout > 0 {
+		return fmt.Sprintf("timed out after %s", timeout)
+	}
+	return err.Error()
+}
+
+// recordFailure adds a failed request to the capture
+func (nc *NetworkCapture) recordFailure(kind string, job *Request, err error, timeout time.Duration, attempts int) {
+	if nc.Metrics != nil {
+		nc.Metrics.AddFailure(kind)
+	}
+	nc.Failures = append(nc.Failures, FailedRequest{
+		URL:      job.URL,
+		Source:   job.Source,
+		Depth:    job.Depth,
+		Kind:     kind,
+		Reason:   failureReason(err, timeout),
+		TimedOut: isTimeout(err),
+		Attempts: attempts,
+	})
+}
+
+// SaveFailures writes the failed requests to failures.json
+func (nc *NetworkCapture) SaveFailures() {
+	if len(nc.Failures) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(nc.Failures, "", "  ")
+	if err != nil {
+		slog.Error("Failed to encode failures", "error", err)
+		return
+	}
+
+	failuresFile := filepath.Join(nc.OutputDir, "failures.json")
+	if err := os.WriteFile(failuresFile, data, 0644); err != nil {
+		slog.Error("Failed to write failures file", "error", err)
+		return
+	}
+	slog.Info("Recorded failed requests", "count", len(nc.Failures), "file", failuresFile)
+}
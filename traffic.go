@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// Exchange is a request and its response observed on the browser's network
+type Exchange struct {
+	RequestID       network.RequestID
+	Method          string
+	URL             string
+	RequestHeaders  map[string]string
+	RequestBody     string
+	ResourceType    string
+	DocumentURL     string
+	Referrer        string
+	Depth           int
+	Status          int
+	StatusText      string
+	Protocol        string
+	ResponseHeaders map[string]string
+	MimeType        string
+	Body            []byte
+	Started         time.Time
+}
+
+// TrafficRecorder follows the requests the browser makes while pages load
+// and hands completed exchanges, including their bodies, to its handlers.
+type TrafficRecorder struct {
+	mu        sync.Mutex
+	exchanges map[network.RequestID]*Exchange
+	documents map[string]network.RequestID
+	page      *Request
+	handlers  []func(*Exchange)
+	pending   sync.WaitGroup
+}
+
+// NewTrafficRecorder creates an empty traffic recorder
+func NewTrafficRecorder() *TrafficRecorder {
+	return &TrafficRecorder{
+		exchanges: make(map[network.RequestID]*Exchange),
+		documents: make(map[string]network.RequestID),
+	}
+}
+
+// Handle registers a handler for completed exchanges.
+// Response bodies are only retrieved when at least one handler is registered.
+func (tr *TrafficRecorder) Handle(handler func(*Exchange)) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.handlers = append(tr.handlers, handler)
+}
+
+// SetPage sets the crawl request whose page is currently loading
+func (tr *TrafficRecorder) SetPage(job *Request) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.page = job
+}
+
+// Page returns the crawl request whose page is currently loading
+func (tr *TrafficRecorder) Page() *Request {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return tr.page
+}
+
+// DocumentRequestID returns the ID of the latest document request for a URL
+func (tr *TrafficRecorder) DocumentRequestID(urlStr string) (network.RequestID, bool) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	requestID, ok := tr.documents[urlStr]
+	return requestID, ok
+}
+
+// Wait blocks until every completed exchange has been handled
+func (tr *TrafficRecorder) Wait() {
+	tr.pending.Wait()
+}
+
+// Record hands an exchange made outside the browser to the handlers,
+// attributed to the page currently loading
+func (tr *TrafficRecorder) Record(exchange *Exchange) {
+	tr.mu.Lock()
+	if tr.page != nil {
+		exchange.Depth = tr.page.Depth
+		exchange.Referrer = tr.page.URL
+	}
+	tr.mu.Unlock()
+	tr.complete(exchange)
+}
+
+// listenForTraffic records the requests the browser makes while pages load
+func (nc *NetworkCapture) listenForTraffic(ctx context.Context) {
+	tr := nc.Traffic
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		switch ev := ev.(type) {
+		case *network.EventRequestWillBeSent:
+			if ev.Request == nil {
+				return
+			}
+			req := ev.Request
+			body := postData(req)
+			nc.Inventory.AddRequest(req.Method, req.URL, ev.DocumentURL, headerValue(req.Headers, "Content-Type"), body)
+
+			tr.mu.Lock()
+			// A redirect reuses the request ID, so the previous hop completes here
+			previous, redirected := tr.exchanges[ev.RequestID]
+			if redirected && ev.RedirectResponse != nil {
+				previous.setResponse(ev.RedirectResponse)
+			}
+			exchange := &Exchange{
+				RequestID:      ev.RequestID,
+				Method:         req.Method,
+				URL:            req.URL,
+				RequestHeaders: flattenHeaders(req.Headers),
+				RequestBody:    body,
+				ResourceType:   string(ev.Type),
+				DocumentURL:    ev.DocumentURL,
+				Started:        time.Now(),
+			}
+			if tr.page != nil {
+				exchange.Depth = tr.page.Depth
+				exchange.Referrer = tr.page.Source
+				if req.URL != tr.page.URL {
+					exchange.Referrer = tr.page.URL
+				}
+			}
+			tr.exchanges[ev.RequestID] = exchange
+			if ev.Type == network.ResourceTypeDocument {
+				tr.documents[req.URL] = ev.RequestID
+			}
+			tr.mu.Unlock()
+
+			if redirected && ev.RedirectResponse != nil {
+				tr.complete(previous)
+			}
+
+		case *network.EventResponseReceived:
+			tr.mu.Lock()
+			if exchange, ok := tr.exchanges[ev.RequestID]; ok && ev.Response != nil {
+				exchange.setResponse(ev.Response)
+			}
+			tr.mu.Unlock()
+
+		case *network.EventLoadingFinished:
+			tr.mu.Lock()
+			exchange, ok := tr.exchanges[ev.RequestID]
+			delete(tr.exchanges, ev.RequestID)
+			wantBody := len(tr.handlers) > 0
+			tr.mu.Unlock()
+			if !ok || !wantBody {
+				return
+			}
+
+			// Commands cannot be issued from the listener itself
+			tr.pending.Add(1)
+			go func() {
+				defer tr.pending.Done()
+				c := chromedp.FromContext(ctx)
+				body, err := network.GetResponseBody(ev.RequestID).Do(cdp.WithExecutor(ctx, c.Target))
+				if err == nil {
+					exchange.Body = body
+				}
+				tr.complete(exchange)
+			}()
+
+		case *network.EventLoadingFailed:
+			tr.mu.Lock()
+			delete(tr.exchanges, ev.RequestID)
+			tr.mu.Unlock()
+		}
+	})
+}
+
+// complete passes an exchange with a response to the handlers
+func (tr *TrafficRecorder) complete(exchange *Exchange) {
+	if exchange.Status == 0 {
+		return
+	}
+	tr.mu.Lock()
+	handlers := append([](func(*Exchange))(nil), tr.handlers...)
+	tr.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(exchange)
+	}
+}
+
+func (ex *Exchange) setResponse(resp *network.Response) {
+	ex.Status = int(resp.Status)
+	ex.StatusText = resp.StatusText
+	ex.Protocol = resp.Protocol
+	ex.ResponseHeaders = flattenHeaders(resp.Headers)
+	ex.MimeType = resp.MimeType
+	if len(resp.RequestHeaders) > 0 {
+		// Prefer the headers that were actually sent over the provisional ones
+		ex.RequestHeaders = flattenHeaders(resp.RequestHeaders)
+	}
+}
+
+// flattenHeaders converts CDP headers to strings
+func flattenHeaders(headers network.Headers) map[string]string {
+	flat := make(map[string]string, len(headers))
+	for key, value := range headers {
+		flat[key] = fmt.Sprint(value)
+	}
+	return flat
+}
+
+// headerValue looks up a header case-insensitively
+func headerValue(headers network.Headers, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+// postData reassembles the body of a request from its post data entries
+func postData(req *network.Request) string {
+	var body strings.Builder
+	for _, entry := range req.PostDataEntries {
+		data, err := base64.StdEncoding.DecodeString(entry.Bytes)
+		if err != nil {
+			continue
+		}
+		body.Write(data)
+	}
+	return body.String()
+}
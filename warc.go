@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// warcSkippedHeaders are response headers that no longer describe the
+// decoded body the browser hands back, so they are left out of records
+var warcSkippedHeaders = map[string]bool{
+	"content-encoding":  true,
+	"content-length":    true,
+	"transfer-encoding": true,
+}
+
+// WARCWriter writes captured exchanges as WARC/1.1 records.
+// It is safe for concurrent use.
+type WARCWriter struct {
+	mu         sync.Mutex
+	file       *os.File
+	compress   bool
+	warcinfoID string
+	seen       map[string]bool
+}
+
+// NewWARCWriter creates a WARC file and writes its warcinfo record.
+// Files ending in .gz are written with one gzip member per record.
+func NewWARCWriter(path string) (*WARCWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WARC file: %w", err)
+	}
+
+	w := &WARCWriter{
+		file:     file,
+		compress: strings.HasSuffix(path, ".gz"),
+		seen:     make(map[string]bool),
+	}
+
+	info := fmt.Sprintf("software: crawler\r\nformat: WARC File Format 1.1\r\nconformsTo: http://iipc.github.io/warc-specifications/specifications/warc-format/warc-1.1/\r\nfilename: %s\r\n", filepath.Base(path))
+	w.warcinfoID = newWARCRecordID()
+	if err := w.writeRecord([][2]string{
+		{"WARC-Type", "warcinfo"},
+		{"WARC-Record-ID", w.warcinfoID},
+		{"WARC-Date", warcDate(time.Now())},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte(info)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// WriteExchange writes the request, response and metadata records for an exchange.
+// Repeated fetches of the same URL with an identical body are written once.
+func (w *WARCWriter) WriteExchange(ex *Exchange) error {
+	payloadDigest := warcDigest(ex.Body)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	key := ex.Method + " " + ex.URL + " " + payloadDigest
+	if w.seen[key] {
+		return nil
+	}
+	w.seen[key] = true
+
+	date := warcDate(ex.Started)
+	responseID := newWARCRecordID()
+
+	responseBlock := warcResponseBlock(ex)
+	if err := w.writeRecord([][2]string{
+		{"WARC-Type", "response"},
+		{"WARC-Record-ID", responseID},
+		{"WARC-Warcinfo-ID", w.warcinfoID},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", ex.URL},
+		{"Content-Type", "application/http;msgtype=response"},
+		{"WARC-Payload-Digest", payloadDigest},
+		{"WARC-Block-Digest", warcDigest(responseBlock)},
+	}, responseBlock); err != nil {
+		return err
+	}
+
+	requestBlock := warcRequestBlock(ex)
+	if err := w.writeRecord([][2]string{
+		{"WARC-Type", "request"},
+		{"WARC-Record-ID", newWARCRecordID()},
+		{"WARC-Warcinfo-ID", w.warcinfoID},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", ex.URL},
+		{"WARC-Concurrent-To", responseID},
+		{"Content-Type", "application/http;msgtype=request"},
+		{"WARC-Block-Digest", warcDigest(requestBlock)},
+	}, requestBlock); err != nil {
+		return err
+	}
+
+	var metadata strings.Builder
+	if ex.Referrer != "" {
+		fmt.Fprintf(&metadata, "via: %s\r\n", ex.Referrer)
+	}
+	fmt.Fprintf(&metadata, "depth: %d\r\n", ex.Depth)
+	if ex.ResourceType != "" {
+		fmt.Fprintf(&metadata, "resourceType: %s\r\n", ex.ResourceType)
+	}
+	return w.writeRecord([][2]string{
+		{"WARC-Type", "metadata"},
+		{"WARC-Record-ID", newWARCRecordID()},
+		{"WARC-Warcinfo-ID", w.warcinfoID},
+		{"WARC-Date", date},
+		{"WARC-Target-URI", ex.URL},
+		{"WARC-Refers-To", responseID},
+		{"Content-Type", "application/warc-fields"},
+	}, []byte(metadata.String()))
+}
+
+// Close flushes and closes the WARC file
+func (w *WARCWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+// writeRecord writes a single record. The caller must hold w.mu,
+// except while the writer is still being constructed.
+func (w *WARCWriter) writeRecord(headers [][2]string, block []byte) error {
+	var out io.Writer = w.file
+	var gz *gzip.Writer
+	if w.compress {
+		gz = gzip.NewWriter(w.file)
+		out = gz
+	}
+
+	var record bytes.Buffer
+	record.WriteString("WARC/1.1\r\n")
+	for _, header := range headers {
+		fmt.Fprintf(&record, "%s: %s\r\n", header[0], header[1])
+	}
+	fmt.Fprintf(&record, "Content-Length: %d\r\n\r\n", len(block))
+	record.Write(block)
+	record.WriteString("\r\n\r\n")
+
+	if _, err := out.Write(record.Bytes()); err != nil {
+		return fmt.Errorf("failed to write WARC record: %w", err)
+	}
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return fmt.Errorf("failed to compress WARC record: %w", err)
+		}
+	}
+	return nil
+}
+
+// warcResponseBlock reconstructs the HTTP response message for an exchange
+func warcResponseBlock(ex *Exchange) []byte {
+	statusText := ex.StatusText
+	if statusText == "" {
+		statusText = http.StatusText(ex.Status)
+	}
+
+	var block bytes.Buffer
+	fmt.Fprintf(&block, "HTTP/1.1 %d %s\r\n", ex.Status, statusText)
+	writeWARCHeaders(&block, ex.ResponseHeaders, warcSkippedHeaders)
+	fmt.Fprintf(&block, "Content-Length: %d\r\n\r\n", len(ex.Body))
+	block.Write(ex.Body)
+	return block.Bytes()
+}
+
+// warcRequestBlock reconstructs the HTTP request message for an exchange
+func warcRequestBlock(ex *Exchange) []byte {
+	target := ex.URL
+	host := ""
+	if parsedURL, err := url.Parse(ex.URL); err == nil {
+		target = parsedURL.RequestURI()
+		host = parsedURL.Host
+	}
+
+	var block bytes.Buffer
+	fmt.Fprintf(&block, "%s %s HTTP/1.1\r\n", ex.Method, target)
+	hasHost := false
+	for key := range ex.RequestHeaders {
+		if strings.EqualFold(key, "host") {
+			hasHost = true
+		}
+	}
+	if !hasHost && host != "" {
+		fmt.Fprintf(&block, "Host: %s\r\n", host)
+	}
+	writeWARCHeaders(&block, ex.RequestHeaders, nil)
+	block.WriteString("\r\n")
+	block.WriteString(ex.RequestBody)
+	return block.Bytes()
+}
+
+// writeWARCHeaders writes headers in a stable order. Values the browser
+// joined with newlines, such as repeated Set-Cookie headers, are split again.
+func writeWARCHeaders(w io.Writer, headers map[string]string, skip map[string]bool) {
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		// HTTP/2 pseudo-headers have no place in an HTTP/1.1 message
+		if strings.HasPrefix(key, ":") || skip[strings.ToLower(key)] {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range strings.Split(headers[key], "\n") {
+			fmt.Fprintf(w, "%s: %s\r\n", key, value)
+		}
+	}
+}
+
+// warcDigest returns the base32 SHA-1 digest used in WARC headers
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// warcDate formats a time as a WARC-Date
+func warcDate(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format("2006-01-02T15:04:05Z")
+}
+
+// newWARCRecordID returns a random UUID URN for WARC-Record-ID
+func newWARCRecordID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16])
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// warcRecord is a record read back from a WARC file
+type warcRecord struct {
+	version string
+	headers map[string]string
+	block   []byte
+}
+
+// readWARCRecords reads a gzipped WARC file, checking that every gzip
+// member holds exactly one record
+func readWARCRecords(t *testing.T, path string) []warcRecord {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	in := bufio.NewReader(file)
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []warcRecord
+	for {
+		zr.Multistream(false)
+		member, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("failed to read gzip member %d: %v", len(records), err)
+		}
+		records = append(records, parseWARCRecord(t, member))
+
+		if err := zr.Reset(in); err == io.EOF {
+			return records
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// parseWARCRecord parses a single record, which must fill the data
+func parseWARCRecord(t *testing.T, data []byte) warcRecord {
+	t.Helper()
+	headerEnd := bytes.Index(data, []byte("\r\n\r\n"))
+	if headerEnd < 0 {
+		t.Fatalf("record has no header block: %q", data)
+	}
+	lines := strings.Split(string(data[:headerEnd]), "\r\n")
+	record := warcRecord{version: lines[0], headers: make(map[string]string)}
+	for _, line := range lines[1:] {
+		name, value, _ := strings.Cut(line, ": ")
+		record.headers[name] = value
+	}
+
+	length, err := strconv.Atoi(record.headers["Content-Length"])
+	if err != nil {
+		t.Fatalf("bad Content-Length %q", record.headers["Content-Length"])
+	}
+	rest := data[headerEnd+4:]
+	if len(rest) != length+4 || !bytes.HasSuffix(rest, []byte("\r\n\r\n")) {
+		t.Fatalf("%s record holds %d bytes after its headers, want Content-Length %d and the record end",
+			record.headers["WARC-Type"], len(rest), length)
+	}
+	record.block = rest[:length]
+	return record
+}
+
+func TestWARCWriterRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crawl.warc.gz")
+	w, err := NewWARCWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exchange := &Exchange{
+		Method:         "GET",
+		URL:            "https://example.com/page?q=1",
+		Referrer:       "https://example.com/",
+		Depth:          1,
+		ResourceType:   "Document",
+		RequestHeaders: map[string]string{"Accept": "text/html", ":authority": "example.com"},
+		Status:         200,
+		ResponseHeaders: map[string]string{
+			"Content-Type":     "text/html",
+			"Content-Encoding": "gzip",
+			"Set-Cookie":       "a=1\nb=2",
+		},
+		Body:    []byte("<html>hello</html>"),
+		Started: time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC),
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.WriteExchange(exchange); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readWARCRecords(t, path)
+	var types []string
+	for _, record := range records {
+		types = append(types, record.headers["WARC-Type"])
+		if record.version != "WARC/1.1" {
+			t.Errorf("%s record version = %q, want WARC/1.1", record.headers["WARC-Type"], record.version)
+		}
+		if digest := record.headers["WARC-Block-Digest"]; digest != "" && digest != warcDigest(record.block) {
+			t.Errorf("%s record WARC-Block-Digest = %s, want %s", record.headers["WARC-Type"], digest, warcDigest(record.block))
+		}
+	}
+	if got := strings.Join(types, ","); got != "warcinfo,response,request,metadata" {
+		t.Fatalf("record types = %s, want the repeated exchange written once", got)
+	}
+
+	info, response, request, metadata := records[0], records[1], records[2], records[3]
+	if !strings.Contains(string(info.block), "filename: crawl.warc.gz\r\n") {
+		t.Errorf("warcinfo block = %q", info.block)
+	}
+
+	if response.headers["WARC-Target-URI"] != exchange.URL || response.headers["WARC-Date"] != "2024-01-31T12:00:00Z" {
+		t.Errorf("response headers = %v", response.headers)
+	}
+	if response.headers["WARC-Payload-Digest"] != warcDigest(exchange.Body) {
+		t.Errorf("WARC-Payload-Digest = %s, want the digest of the body", response.headers["WARC-Payload-Digest"])
+	}
+	wantResponse := "HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nSet-Cookie: a=1\r\nSet-Cookie: b=2\r\nContent-Length: 18\r\n\r\n<html>hello</html>"
+	if string(response.block) != wantResponse {
+		t.Errorf("response block =\n%q\nwant\n%q", response.block, wantResponse)
+	}
+
+	wantRequest := "GET /page?q=1 HTTP/1.1\r\nHost: example.com\r\nAccept: text/html\r\n\r\n"
+	if string(request.block) != wantRequest {
+		t.Errorf("request block =\n%q\nwant\n%q", request.block, wantRequest)
+	}
+	if request.headers["WARC-Concurrent-To"] != response.headers["WARC-Record-ID"] {
+		t.Error("request record is not concurrent to its response")
+	}
+
+	if metadata.headers["WARC-Refers-To"] != response.headers["WARC-Record-ID"] {
+		t.Error("metadata record does not refer to its response")
+	}
+	if want := "via: https://example.com/\r\ndepth: 1\r\nresourceType: Document\r\n"; string(metadata.block) != want {
+		t.Errorf("metadata block = %q, want %q", metadata.block, want)
+	}
+	for _, record := range records[1:] {
+		if record.headers["WARC-Warcinfo-ID"] != info.headers["WARC-Record-ID"] {
+			t.Errorf("%s record does not point at the warcinfo record", record.headers["WARC-Type"])
+		}
+	}
+}